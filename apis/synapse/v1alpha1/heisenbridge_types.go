@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -41,10 +42,34 @@ type HeisenbridgeSpec struct {
 	// * 3 corresponds to "-vvv"
 	VerboseLevel int `json:"verboseLevel,omitempty"`
 
+	// Configuration of the identd service of the bridge
+	Identd *HeisenbridgeIdentd `json:"identd,omitempty"`
+
 	// +kubebuilder:validation:Required
 
 	// Name of the Synapse instance, living in the same namespace.
 	Synapse HeisenbridgeSynapseSpec `json:"synapse"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to check, after each reconciliation and at most once a
+	// minute, that the Synapse client API answers on the URL used by the
+	// bridge. The request is sent by a short-lived Job running in the bridge
+	// namespace, whose Pod has the labels of the Heisenbridge Pods, so that it
+	// goes through the same NetworkPolicies. The result is reported in
+	// Status.SynapseConnectivity.
+	SelfTest bool `json:"selfTest,omitempty"`
+
+	// Names of Secrets, living in the same namespace, used to pull the
+	// Heisenbridge image from a private registry. The Secrets must exist,
+	// otherwise the State is set to FAILED.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Resource requests set on the Heisenbridge container, for each resource
+	// which has neither a request nor a limit, e.g. in namespaces with a
+	// ResourceQuota. Overrides the operator's --default-cpu-request and
+	// --default-memory-request.
+	DefaultRequests corev1.ResourceList `json:"defaultRequests,omitempty"`
 }
 
 type HeisenbridgeSynapseSpec struct {
@@ -58,6 +83,20 @@ type HeisenbridgeSynapseSpec struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+type HeisenbridgeIdentd struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to run the identd service, answering the ident queries
+	// of the IRC servers. IRC servers send them to port 113 of the address
+	// the bridge connects from, which is the address of the node when the
+	// pod traffic is masqueraded. The service is therefore published on the
+	// host port 113 of the node running the bridge, while it listens on an
+	// unprivileged port in the container. As the host port can only be
+	// bound once per node, the Deployment is then recreated instead of
+	// rolled out.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
 type HeisenbridgeConfigMap struct {
 	// +kubebuilder:validation:Required
 
@@ -76,6 +115,28 @@ type HeisenbridgeStatus struct {
 
 	// Reason for the current Heisenbridge State
 	Reason string `json:"reason,omitempty"`
+
+	// In-cluster URLs of the endpoints served by the bridge: 'appservice'.
+	// Heisenbridge doesn't serve metrics.
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+
+	// Result of the last self-test of the connection to Synapse. Only set
+	// if Spec.SelfTest is enabled.
+	SynapseConnectivity *BridgeStatusSynapseConnectivity `json:"synapseConnectivity,omitempty"`
+}
+
+// BridgeStatusSynapseConnectivity is the result of the self-test of the
+// connection of a bridge to Synapse, shared by the Heisenbridge and
+// MautrixSignal Status
+type BridgeStatusSynapseConnectivity struct {
+	// State of the connection to Synapse, either OK or FAILED
+	State string `json:"state,omitempty"`
+
+	// Reason for a FAILED State
+	Reason string `json:"reason,omitempty"`
+
+	// URL of the Synapse endpoint which was queried
+	URL string `json:"url,omitempty"`
 }
 
 //+kubebuilder:object:root=true
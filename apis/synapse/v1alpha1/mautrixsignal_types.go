@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +37,206 @@ type MautrixSignalSpec struct {
 
 	// Name of the Synapse instance, living in the same namespace.
 	Synapse MautrixSignalSynapseSpec `json:"synapse"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to check, after each reconciliation and at most once a
+	// minute, that the Synapse client API answers on the URL used by the
+	// bridge. The request is sent by a short-lived Job running in the bridge
+	// namespace, whose Pod has the labels of the mautrix-signal Pods, so that it
+	// goes through the same NetworkPolicies. The result is reported in
+	// Status.SynapseConnectivity.
+	SelfTest bool `json:"selfTest,omitempty"`
+
+	// End-to-bridge encryption support options
+	Encryption *MautrixSignalEncryption `json:"encryption,omitempty"`
+
+	// Messages sent upon joining a management room. Markdown is supported.
+	// Messages left empty keep their default value.
+	ManagementRoomText *MautrixSignalManagementRoomText `json:"managementRoomText,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[^a-zA-Z0-9\s]\S*$`
+
+	// The prefix for commands, only required in non-management rooms. It
+	// must start with a non-alphanumeric character. If left empty, the
+	// bridge default "!signal" is used.
+	CommandPrefix string `json:"commandPrefix,omitempty"`
+
+	// Relay mode options
+	Relay *MautrixSignalRelay `json:"relay,omitempty"`
+
+	// Options for the connection of the bridge to the Synapse homeserver
+	Homeserver *MautrixSignalHomeserver `json:"homeserver,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Whether to receive ephemeral events (typing notifications, read
+	// receipts) via appservice transactions (MSC2409). Requires Synapse
+	// 1.22+. When enabled, the bridge no longer uses /sync to get those
+	// events for double puppets (sync_with_custom_puppets is disabled).
+	EphemeralEvents bool `json:"ephemeralEvents,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Whether the bridge should send a read receipt from the bridge bot when
+	// a message has been sent to Signal.
+	DeliveryReceipts bool `json:"deliveryReceipts,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Whether the bridge should send the message status as a custom
+	// com.beeper.message_send_status event. Useful to check whether messages
+	// actually reach Signal.
+	MessageStatusEvents bool `json:"messageStatusEvents,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^([A-Za-z0-9_-][A-Za-z0-9_.-]*/)*[A-Za-z0-9_-][A-Za-z0-9_.-]*$`
+
+	// Working directory of the mautrix-signal and signald containers,
+	// relative to their persistent data volume (mounted on /data and
+	// /signald respectively). If left empty, the root of the data volume is
+	// used.
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// +kubebuilder:default:=true
+
+	// Set to true to point the HOME environment variable of the
+	// mautrix-signal and signald containers to their working directory, so
+	// that ~-relative paths resolve to the persistent data volume.
+	SetHome *bool `json:"setHome,omitempty"`
+
+	// Configuration of the storage used by the mautrix-signal bridge and
+	// signald
+	Storage *MautrixSignalStorage `json:"storage,omitempty"`
+
+	// Names of Secrets, living in the same namespace, used to pull the
+	// mautrix-signal and signald images from private registries. The Secrets
+	// must exist, otherwise the State is set to FAILED.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Resource requests set on the containers of the mautrix-signal and
+	// signald Pods, for each resource which has neither a request nor a
+	// limit, e.g. in namespaces with a ResourceQuota. Overrides the
+	// operator's --default-cpu-request and --default-memory-request.
+	DefaultRequests corev1.ResourceList `json:"defaultRequests,omitempty"`
+
+	// Graceful shutdown options of the mautrix-signal and signald Pods
+	Shutdown *MautrixSignalShutdown `json:"shutdown,omitempty"`
+
+	// Node labels the mautrix-signal and signald Pods must be scheduled on
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the mautrix-signal and signald Pods
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Scheduling constraints of the mautrix-signal and signald Pods
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+}
+
+type MautrixSignalShutdown struct {
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default:=5
+
+	// Number of seconds the mautrix-signal and signald containers wait in a
+	// preStop hook before being sent SIGTERM. This lets in-flight messages
+	// be delivered, and signald flush its state, before the bridge
+	// disconnects. Set to 0 to disable the preStop hook.
+	DrainSeconds int64 `json:"drainSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// Duration in seconds the mautrix-signal and signald Pods are given to
+	// terminate, including the drain period. If left empty, the Kubernetes
+	// default of 30 seconds applies.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+}
+
+type MautrixSignalStorage struct {
+	// Name of the StorageClass used by the mautrix-signal and signald PVCs.
+	// If left empty, the cluster default StorageClass applies. As the
+	// storage class of a PVC is immutable, changing it has no effect on
+	// existing PVCs.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+type MautrixSignalHomeserver struct {
+	// +kubebuilder:validation:Minimum=1
+
+	// Maximum number of simultaneous HTTP connections to the homeserver. If
+	// left empty, the bridge default (100) is used.
+	ConnectionLimit int `json:"connectionLimit,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+
+	// Number of retries for all HTTP requests if the homeserver isn't
+	// reachable. If left empty, the bridge default (4) is used.
+	HTTPRetryCount int `json:"httpRetryCount,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Whether asynchronous uploads via MSC2246 should be enabled for media.
+	// Requires a Synapse version supporting MSC2246, with the corresponding
+	// experimental feature enabled.
+	AsyncMedia bool `json:"asyncMedia,omitempty"`
+
+	// +kubebuilder:validation:Enum=standard;asmux;hungry
+
+	// Software of the homeserver the bridge talks to, allowing the bridge to
+	// optimize for it. Use "asmux" if Synapse is fronted by mautrix-asmux.
+	// If left empty, the bridge default (standard) is used.
+	Software string `json:"software,omitempty"`
+}
+
+type MautrixSignalRelay struct {
+	// +kubebuilder:default:=false
+
+	// Whether relay mode should be allowed. If allowed, the set-relay command
+	// can be used to turn any authenticated user into a relaybot for that
+	// chat.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The formats to use when sending messages to Signal via a relay user,
+	// indexed by message type (e.g. m.text, m.notice). Formats which are not
+	// listed keep their default value. Available variables are
+	// $sender_displayname, $sender_username, $sender_mxid and $message.
+	MessageFormats map[string]string `json:"messageFormats,omitempty"`
+}
+
+type MautrixSignalManagementRoomText struct {
+	// Sent when joining a room
+	Welcome string `json:"welcome,omitempty"`
+
+	// Sent when joining a management room and the user is already logged in
+	WelcomeConnected string `json:"welcomeConnected,omitempty"`
+
+	// Sent when joining a management room and the user is not logged in
+	WelcomeUnconnected string `json:"welcomeUnconnected,omitempty"`
+
+	// Optional extra text sent when joining a management room
+	AdditionalHelp string `json:"additionalHelp,omitempty"`
+}
+
+type MautrixSignalEncryption struct {
+	// +kubebuilder:default:=false
+
+	// Allow encryption, work in group chat rooms with e2ee enabled
+	Allow bool `json:"allow,omitempty"`
+
+	// Options for automatic key sharing. Key sharing can only be enabled if
+	// encryption is allowed.
+	KeySharing *MautrixSignalEncryptionKeySharing `json:"keySharing,omitempty"`
+}
+
+type MautrixSignalEncryptionKeySharing struct {
+	// +kubebuilder:default:=false
+
+	// Enable key sharing. If enabled, key requests for rooms where users are
+	// in will be fulfilled.
+	Allow bool `json:"allow,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Require the requesting device to have a valid cross-signing signature
+	RequireCrossSigning bool `json:"requireCrossSigning,omitempty"`
 }
 
 type MautrixSignalSynapseSpec struct {
@@ -44,8 +245,9 @@ type MautrixSignalSynapseSpec struct {
 	// Name of the Synapse instance
 	Name string `json:"name"`
 
-	// Namespace of the Synapse instance
-	// TODO: Complete
+	// Namespace of the Synapse instance. If left empty, the MautrixSignal
+	// namespace is used. Cross-namespace references are not supported: the
+	// Synapse instance must live in the same namespace as the bridge.
 	Namespace string `json:"namespace,omitempty"`
 }
 
@@ -75,6 +277,15 @@ type MautrixSignalStatus struct {
 
 	// Values is set to true if deploying on OpenShift
 	IsOpenshift bool `json:"isOpenshift,omitempty"`
+
+	// In-cluster URLs of the endpoints served by the bridge, as configured
+	// in config.yaml: 'appservice' and, if enabled, 'provisioning' and
+	// 'metrics'
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+
+	// Result of the last self-test of the connection to Synapse. Only set
+	// if Spec.SelfTest is enabled.
+	SynapseConnectivity *BridgeStatusSynapseConnectivity `json:"synapseConnectivity,omitempty"`
 }
 
 type MautrixSignalStatusSynapse struct {
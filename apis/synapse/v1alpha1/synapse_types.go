@@ -17,12 +17,24 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// ForceReconcileAnnotation can be set on a Synapse object to force the
+// regeneration of its configuration and the rollout of its Deployment. The
+// annotation is removed once processed.
+const ForceReconcileAnnotation = "synapse.opdev.io/force-reconcile"
+
+// RotateMacaroonSecretKeyAnnotation can be set on a Synapse object to
+// generate a new macaroon_secret_key. This invalidates all access tokens,
+// logging out every user. The annotation is removed once processed.
+const RotateMacaroonSecretKeyAnnotation = "synapse.opdev.io/rotate-macaroon-secret-key"
+
 // SynapseSpec defines the desired state of Synapse
 type SynapseSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -42,10 +54,538 @@ type SynapseSpec struct {
 	// 'database' section will be overwritten.
 	CreateNewPostgreSQL bool `json:"createNewPostgreSQL,omitempty"`
 
+	// Secrets used by Synapse, read from an existing Secret instead of being
+	// generated by the operator. Requires Spec.Homeserver.Values.
+	Secrets *SynapseSecrets `json:"secrets,omitempty"`
+
+	// Options for the PostgreSQL database used by Synapse, either created
+	// with CreateNewPostgreSQL or provided through an existing Secret
+	Database *SynapseDatabase `json:"database,omitempty"`
+
+	// Container image of Synapse. It must be pinned to either a tag (e.g.
+	// matrixdotorg/synapse:v1.71.0) or a digest (e.g.
+	// matrixdotorg/synapse@sha256:<digest>). Changing the image rolls out the
+	// Synapse Deployment. If left empty, matrixdotorg/synapse:v1.71.0 is used.
+	Image string `json:"image,omitempty"`
+
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+
+	// Pull policy of the Synapse image. If left empty, the Kubernetes
+	// default applies (IfNotPresent for images pinned to a tag or digest).
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Names of Secrets, living in the same namespace, used to pull images
+	// from private registries. They apply to every Deployment and Job created
+	// for this Synapse instance, including the inline bridges and the
+	// well-known server. The Secrets must exist, otherwise the State is set
+	// to FAILED.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1
+	// +kubebuilder:default:=1
+
+	// Number of replicas of the Synapse main process. Synapse doesn't
+	// support several main processes sharing the same database, and scales
+	// out through workers instead, such as the media worker: only 0 and 1
+	// are accepted. If left empty, 1 is used.
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Compute resources (CPU and memory requests and limits) of the Synapse
+	// container. Limits must not be lower than requests. Changing them rolls
+	// out the Synapse Deployment. If left empty, no requests or limits are
+	// set.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Resource requests set on the containers of the Pods managed for this
+	// Synapse instance, for each resource which has neither a request nor a
+	// limit, e.g. in namespaces with a ResourceQuota. Overrides the
+	// operator's --default-cpu-request and --default-memory-request. The
+	// bridges enabled in Spec.Bridges use the same requests.
+	DefaultRequests corev1.ResourceList `json:"defaultRequests,omitempty"`
+
 	// +kubebuilder:default:=false
 
 	// Set to true if deploying on OpenShift
 	IsOpenshift bool `json:"isOpenshift,omitempty"`
+
+	// Holds the configuration of the TURN server used by Synapse for VoIP
+	TURN *SynapseTURN `json:"turn,omitempty"`
+
+	// Holds the configuration of the Redis instance used by Synapse for
+	// replication between its processes
+	Redis *SynapseRedis `json:"redis,omitempty"`
+
+	// Holds the configuration of the media repository worker, serving the
+	// media endpoints on behalf of the Synapse main process
+	MediaWorker *SynapseMediaWorker `json:"mediaWorker,omitempty"`
+
+	// Configuration of the readiness and liveness probes of the Synapse
+	// container
+	Probes *SynapseProbes `json:"probes,omitempty"`
+
+	// Configuration of the storage used by Synapse
+	Storage *SynapseStorage `json:"storage,omitempty"`
+
+	// Configuration of the Service exposing Synapse
+	Service *SynapseService `json:"service,omitempty"`
+
+	// Configuration of the Ingress exposing Synapse outside of the cluster.
+	// If left empty, no Ingress is created.
+	Ingress *SynapseIngress `json:"ingress,omitempty"`
+
+	// Configuration of the well-known delegation files served for the
+	// server name. If left empty, they are not served by the operator.
+	WellKnown *SynapseWellKnown `json:"wellKnown,omitempty"`
+
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+
+	// DNS policy of the Synapse pods. If left empty, the Kubernetes default
+	// (ClusterFirst) applies. Set to None to rely solely on DNSConfig, e.g.
+	// for split-horizon DNS setups where federation peers must be resolved
+	// by a specific resolver.
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNS parameters of the Synapse pods, merged with the ones generated
+	// from DNSPolicy. Required if DNSPolicy is set to None.
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// Node labels the Synapse pods must be scheduled on, e.g. to pin Synapse
+	// to nodes with large local disks. Also applies to the media worker.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the Synapse pods. Also applies to the media worker.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Scheduling constraints of the Synapse pods. Cannot be combined with
+	// Spec.Database.ColocateWithSynapse, which sets the affinity itself.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Configuration of the Prometheus metrics exposed by Synapse
+	Metrics *SynapseMetrics `json:"metrics,omitempty"`
+
+	// Performance tuning options of the Synapse process, set through the
+	// environment variables read by Synapse on startup. Changing them rolls
+	// out the Synapse Deployment.
+	Performance *SynapsePerformance `json:"performance,omitempty"`
+
+	// Bridges to be deployed and managed alongside Synapse. This is an
+	// alternative to creating Heisenbridge and MautrixSignal objects
+	// referencing this Synapse instance.
+	Bridges *SynapseBridges `json:"bridges,omitempty"`
+
+	// +listType=map
+	// +listMapKey=alias
+
+	// Rooms and spaces to create once Synapse is running. They are created
+	// by a one-shot Job, using the admin API, on behalf of the
+	// @synapse-operator admin user. Rooms whose alias already exists are
+	// skipped. Requires Spec.Homeserver.Values and password login.
+	InitialRooms []SynapseInitialRoom `json:"initialRooms,omitempty"`
+}
+
+type SynapsePerformance struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to run Synapse on the asyncio-based Twisted reactor
+	// instead of the default epoll reactor (SYNAPSE_ASYNC_IO_REACTOR).
+	AsyncIOReactor bool `json:"asyncIOReactor,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+
+	// Multiplier applied to the size of all Synapse caches
+	// (SYNAPSE_CACHE_FACTOR), e.g. "2.0". Must be greater than 0. If left
+	// empty, the Synapse default of 0.5 applies.
+	CacheFactor string `json:"cacheFactor,omitempty"`
+
+	// Multipliers applied to the size of individual caches, keyed by cache
+	// name (e.g. get_users_who_share_room_with_user), taking precedence over
+	// CacheFactor (SYNAPSE_CACHE_FACTOR_<NAME>). Cache names may only
+	// contain letters, digits and underscores, and factors must be greater
+	// than 0.
+	PerCacheFactors map[string]string `json:"perCacheFactors,omitempty"`
+}
+
+type SynapseInitialRoom struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[^:#\s]+$`
+
+	// Localpart of the room alias, e.g. "general" for #general:<serverName>.
+	// Used to detect whether the room already exists.
+	Alias string `json:"alias"`
+
+	// Name of the room
+	Name string `json:"name,omitempty"`
+
+	// Topic of the room
+	Topic string `json:"topic,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to publish the room in the room directory and let anyone
+	// join it. Otherwise, the room is private and invite-only.
+	Public bool `json:"public,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to create a space instead of a room
+	Space bool `json:"space,omitempty"`
+}
+
+type SynapseSecrets struct {
+	// Name of a Secret, living in the Synapse namespace, holding the
+	// registration_shared_secret, macaroon_secret_key and form_secret keys.
+	// Their values are used verbatim in homeserver.yaml. Useful when secrets
+	// are managed externally (e.g. in Vault) and synced to Kubernetes.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type SynapseDatabase struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to keep the PostgresCluster, and thus the database, when
+	// the Synapse object is deleted. The PostgresCluster is then reused if a
+	// Synapse object with the same name is created again.
+	RetainOnDelete bool `json:"retainOnDelete,omitempty"`
+
+	// Name of a Secret, living in the Synapse namespace, holding the
+	// connection information of an existing PostgreSQL database, under the
+	// host, port, dbname, user and password keys. The homeserver.yaml
+	// 'database' section will be overwritten. Cannot be used together with
+	// CreateNewPostgreSQL.
+	ExternalSecret string `json:"externalSecret,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to create an ExternalName Service, named
+	// <synapse-name>-database, pointing at the host of the external database.
+	// Synapse then connects to the database through this stable in-cluster
+	// name. The host must be a DNS name, not an IP address. Requires
+	// ExternalSecret.
+	ExternalNameService bool `json:"externalNameService,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to prefer scheduling the Synapse pod on the same node as
+	// the primary pod of the PostgresCluster, to reduce the latency of
+	// database queries. Requires CreateNewPostgreSQL.
+	ColocateWithSynapse bool `json:"colocateWithSynapse,omitempty"`
+
+	// Configuration of the pgBackRest backups of the PostgresCluster.
+	// Requires CreateNewPostgreSQL.
+	PGBackRest *SynapseDatabasePGBackRest `json:"pgBackRest,omitempty"`
+}
+
+type SynapseDatabasePGBackRest struct {
+	// +kubebuilder:validation:Enum=pvc;s3
+	// +kubebuilder:default:=pvc
+
+	// Type of the pgBackRest repository: a PersistentVolumeClaim (pvc), or
+	// an S3 (or S3-compatible) bucket (s3). Requires S3 if set to s3.
+	RepoType string `json:"repoType,omitempty"`
+
+	// +kubebuilder:validation:MinLength=6
+
+	// Cron schedule of the full backups. If left empty, no full backup is
+	// scheduled.
+	FullSchedule string `json:"fullSchedule,omitempty"`
+
+	// +kubebuilder:validation:MinLength=6
+
+	// Cron schedule of the incremental backups. If left empty, no
+	// incremental backup is scheduled.
+	IncrementalSchedule string `json:"incrementalSchedule,omitempty"`
+
+	// Configuration of the S3 bucket used as pgBackRest repository
+	S3 *SynapseDatabasePGBackRestS3 `json:"s3,omitempty"`
+}
+
+type SynapseDatabasePGBackRestS3 struct {
+	// +kubebuilder:validation:Required
+
+	// Name of the S3 bucket
+	Bucket string `json:"bucket"`
+
+	// +kubebuilder:validation:Required
+
+	// Endpoint of the S3 service
+	Endpoint string `json:"endpoint"`
+
+	// +kubebuilder:validation:Required
+
+	// Region of the S3 bucket
+	Region string `json:"region"`
+
+	// +kubebuilder:validation:Required
+
+	// Name of a Secret, living in the Synapse namespace, holding the S3
+	// credentials under the s3.conf key, in the pgBackRest configuration
+	// format (repo1-s3-key and repo1-s3-key-secret in the [global] section).
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+type SynapseBridges struct {
+	// Inline configuration of the Heisenbridge (IRC Bridge)
+	Heisenbridge *SynapseBridgesHeisenbridge `json:"heisenbridge,omitempty"`
+
+	// Inline configuration of the mautrix-signal bridge
+	MautrixSignal *SynapseBridgesMautrixSignal `json:"mautrixsignal,omitempty"`
+}
+
+type SynapseBridgesHeisenbridge struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to create a Heisenbridge object, named
+	// <synapse-name>-heisenbridge and managed by this Synapse instance
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Holds information about the ConfigMap containing the heisenbridge.yaml
+	// configuration file. If left empty, a default configuration is used.
+	ConfigMap *HeisenbridgeConfigMap `json:"configMap,omitempty"`
+
+	// +kubebuilder:default:=0
+
+	// Controls the verbosity of the Heisenbrige, from 0 to 3
+	VerboseLevel int `json:"verboseLevel,omitempty"`
+}
+
+type SynapseBridgesMautrixSignal struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to create a MautrixSignal object, named
+	// <synapse-name>-mautrixsignal and managed by this Synapse instance
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Holds information about the ConfigMap containing the config.yaml
+	// configuration file. If left empty, a default configuration is used.
+	ConfigMap *MautrixSignalConfigMap `json:"configMap,omitempty"`
+}
+
+type SynapseMetrics struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to enable the collection of metrics. Metrics are exposed by
+	// a dedicated listener on port 9000.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to create a ConfigMap holding a Grafana dashboard for
+	// Synapse. The ConfigMap is labeled with grafana_dashboard: "1", the
+	// label watched by the Grafana dashboard sidecar. Only used if metrics
+	// are enabled.
+	GrafanaDashboard bool `json:"grafanaDashboard,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to add the prometheus.io/scrape, prometheus.io/port and
+	// prometheus.io/path annotations to the Synapse Service, for Prometheus
+	// instances discovering their targets through annotations. Only used if
+	// metrics are enabled.
+	PrometheusAnnotations bool `json:"prometheusAnnotations,omitempty"`
+}
+
+type SynapseService struct {
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +kubebuilder:default:=None
+
+	// Session affinity of the Synapse Service. Set to ClientIP to route the
+	// requests of a given client to the same Synapse pod. This is a stopgap
+	// for multi-replica setups, until requests are routed to workers.
+	SessionAffinity string `json:"sessionAffinity,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+
+	// Maximum session sticky time, in seconds. Only valid with the ClientIP
+	// session affinity. If left empty, the Kubernetes default (10800) is used.
+	SessionAffinityTimeoutSeconds int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+}
+
+type SynapseIngress struct {
+	// +kubebuilder:validation:Required
+
+	// Host name on which the client endpoints (/_matrix and
+	// /_synapse/client) are exposed
+	Host string `json:"host"`
+
+	// Name of the IngressClass implementing the Ingress. If left empty, the
+	// default IngressClass of the cluster is used.
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// Name of the Secret, living in the same namespace, holding the TLS
+	// certificate of the Ingress hosts. If left empty, TLS is not
+	// terminated by the Ingress.
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Annotations of the Ingress, e.g. to configure the ingress controller
+	// or cert-manager
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Exposes the federation endpoints on a dedicated host
+	Federation *SynapseIngressFederation `json:"federation,omitempty"`
+}
+
+type SynapseIngressFederation struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to route the federation endpoints (/_matrix/federation
+	// and /_matrix/key) of the federation host to Synapse. Remote servers
+	// reach the Ingress on port 443, which requires delegation through the
+	// /.well-known/matrix/server file, see Spec.WellKnown.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Host name on which the federation endpoints are exposed. If left
+	// empty, the server name is used.
+	Host string `json:"host,omitempty"`
+}
+
+type SynapseWellKnown struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to deploy an nginx instance serving
+	// /.well-known/matrix/server and /.well-known/matrix/client for the
+	// server name. The operator checks the server file once it is served,
+	// and reports the result in Status.ServerWellKnown. When set back to
+	// false, the well-known Deployment, Service and ConfigMap are deleted.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Delegated server name and port of the m.server field, which remote
+	// servers connect to for federation. If left empty, port 443 of the
+	// server name is used.
+	Server string `json:"server,omitempty"`
+
+	// Base URL of the m.homeserver field, used by clients to reach the
+	// client API. If left empty, 'https://<server_name>' is used.
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// +kubebuilder:default:="docker.io/nginxinc/nginx-unprivileged:1.23"
+
+	// Container image used to serve the well-known files
+	Image string `json:"image,omitempty"`
+}
+
+type SynapseStorage struct {
+	// If set, the media store is kept on a dedicated PVC, separate from the
+	// PVC holding the Synapse data (including the SQLite database, if used).
+	// Otherwise, the media store is located on the Synapse data PVC.
+	MediaStore *SynapseStorageMediaStore `json:"mediaStore,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to use an emptyDir volume instead of a PVC for the Synapse
+	// data. All data, including the signing key and the SQLite database, is
+	// lost when the Pod restarts. Intended for testing and CI only. Cannot be
+	// combined with CreateNewPostgreSQL.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[^/]`
+
+	// Relative path within the data volume to mount as the Synapse data
+	// directory. Allows sharing a single PVC between several workloads,
+	// each using its own subdirectory. If left empty, the volume root is
+	// mounted.
+	SubPath string `json:"subPath,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to wait for the Synapse data PVC to be Bound before
+	// creating the Synapse Deployment. Only applies to storage classes with
+	// an Immediate volume binding mode, as PVCs using WaitForFirstConsumer
+	// storage classes stay Pending until a Pod consumes them.
+	WaitForBound bool `json:"waitForBound,omitempty"`
+
+	// Name of the StorageClass used by the Synapse data and media store
+	// PVCs. If left empty, the cluster default StorageClass applies. As the
+	// storage class of a PVC is immutable, changing it has no effect on
+	// existing PVCs.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+type SynapseStorageMediaStore struct {
+	// +kubebuilder:default:="5Gi"
+
+	// Size of the PVC holding the media store
+	Size string `json:"size,omitempty"`
+}
+
+type SynapseProbes struct {
+	// +kubebuilder:default:="/health"
+	// +kubebuilder:validation:Pattern=`^/`
+
+	// HTTP path queried on the client listener (port 8008) by the readiness
+	// and liveness probes
+	Path string `json:"path,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to query /_matrix/client/versions in the readiness probe,
+	// instead of Path. The pod is then only marked Ready once the client API
+	// answers requests. The liveness probe keeps using Path.
+	ReadinessOnClientAPI bool `json:"readinessOnClientAPI,omitempty"`
+}
+
+type SynapseTURN struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to deploy a coturn TURN server alongside Synapse. A shared
+	// secret is generated and stored in a Secret, and the 'turn_uris' and
+	// 'turn_shared_secret' settings are configured to use the coturn
+	// server. The shared secret is only written in the homeserver secrets
+	// file. When set back to false, the coturn resources are deleted.
+	// Requires the homeserver.yaml to be generated from values.
+	Deploy bool `json:"deploy,omitempty"`
+
+	// +kubebuilder:default:="coturn/coturn:4.6.1"
+
+	// Container image used for the coturn TURN server
+	Image string `json:"image,omitempty"`
+
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	// +kubebuilder:default:="LoadBalancer"
+
+	// Type of the coturn Service. The VoIP clients connect to coturn from
+	// outside of the cluster, so it is exposed either through a
+	// LoadBalancer, along with the UDP relay ports 49160-49169, or on a port
+	// of each node, in which case coturn runs on the host network of its
+	// node.
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+
+	// Public host name or IP address on which the clients reach coturn,
+	// used in 'turn_uris'. Required with a NodePort Service. If left empty
+	// with a LoadBalancer Service, the address assigned to the LoadBalancer
+	// is used. With a LoadBalancer Service, coturn advertises this address
+	// in its relay allocations if it is an IP address, and the address of
+	// the LoadBalancer otherwise.
+	Host string `json:"host,omitempty"`
+}
+
+type SynapseRedis struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to deploy a Redis instance alongside Synapse, and to
+	// configure the 'redis' section of homeserver.yaml to use it. Redis is
+	// required to run Synapse workers. When set back to false, the Redis
+	// Deployment and Service are deleted.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:default:="docker.io/library/redis:7.0"
+
+	// Container image used for Redis
+	Image string `json:"image,omitempty"`
+}
+
+type SynapseMediaWorker struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to deploy a media_repository worker alongside Synapse,
+	// sharing the media store and configuration of the main process. The
+	// media repository of the main process is then disabled, and the
+	// /_matrix/media endpoints must be routed to the '<name>-media-worker'
+	// Service. Requires the values of homeserver.yaml, the managed Redis
+	// and a dedicated media store. When set back to false, the worker
+	// Deployment, Service and ConfigMap are deleted.
+	Enabled bool `json:"enabled,omitempty"`
 }
 
 type SynapseHomeserver struct {
@@ -68,6 +608,13 @@ type SynapseHomeserverConfigMap struct {
 	// Namespace in which the ConfigMap is living. If left empty, the Synapse
 	// namespace is used.
 	Namespace string `json:"namespace,omitempty"`
+
+	// +kubebuilder:default:=true
+
+	// Whether to roll out the Synapse Deployment when the ConfigMap is
+	// modified. If false, the modified configuration is copied but only
+	// loaded by Synapse at its next restart.
+	RolloutOnChange *bool `json:"rolloutOnChange,omitempty"`
 }
 
 type SynapseHomeserverValues struct {
@@ -80,6 +627,644 @@ type SynapseHomeserverValues struct {
 
 	// Whether or not to report anonymized homeserver usage statistics
 	ReportStats bool `json:"reportStats"`
+
+	// +kubebuilder:validation:Pattern=`^https?://`
+
+	// The endpoint to report the anonymized homeserver usage statistics to.
+	// Only used if ReportStats is true. If left empty, the matrix.org
+	// endpoint is configured explicitly.
+	ReportStatsEndpoint string `json:"reportStatsEndpoint,omitempty"`
+
+	// Controls who's allowed to create aliases on this server. The action
+	// in the first rule that matches is taken. If left empty, Synapse's
+	// default (everyone is allowed to create aliases) applies.
+	AliasCreationRules []SynapseHomeserverDirectoryRule `json:"aliasCreationRules,omitempty"`
+
+	// Controls who can publish and which rooms can be published in the
+	// public room list. The action in the first rule that matches is taken.
+	// If left empty, Synapse's default (everyone is allowed to publish)
+	// applies.
+	RoomListPublicationRules []SynapseHomeserverDirectoryRule `json:"roomListPublicationRules,omitempty"`
+
+	// Set to false to disable searching the public room list. When disabled,
+	// all queries of the room directory return an empty list. If left empty,
+	// Synapse's default (enabled) applies.
+	EnableRoomListSearch *bool `json:"enableRoomListSearch,omitempty"`
+
+	// Set to true to allow remote servers to query the public room list of
+	// this server over federation. If left empty, Synapse's default
+	// (disabled) applies.
+	AllowPublicRoomsOverFederation *bool `json:"allowPublicRoomsOverFederation,omitempty"`
+
+	// Set to true to allow unauthenticated clients to query the public room
+	// list of this server. If left empty, Synapse's default (disabled)
+	// applies.
+	AllowPublicRoomsWithoutAuth *bool `json:"allowPublicRoomsWithoutAuth,omitempty"`
+
+	// +kubebuilder:default:=true
+
+	// Whether the default HTTP listener (port 8008) should trust the
+	// X-Forwarded-For header to determine the client IP address. This
+	// should only be enabled when Synapse sits behind a reverse proxy which
+	// sets this header. Otherwise, clients are able to spoof their IP
+	// address, bypassing IP-based rate limits and logging. Ignored if
+	// Listeners is set.
+	XForwarded *bool `json:"xForwarded,omitempty"`
+
+	// Whether the resources of the default HTTP listener (port 8008) should
+	// compress HTTP responses to clients that support it. Helps
+	// bandwidth-constrained deployments, at the cost of CPU. If left empty,
+	// compression is disabled. Ignored if Listeners is set, in which case
+	// compression is configured per resource.
+	CompressResponses *bool `json:"compressResponses,omitempty"`
+
+	// Replaces the 'listeners' section of homeserver.yaml. At least one http
+	// listener serving the client resource must listen on port 8008, which
+	// is the port exposed by the Synapse Service and queried by the probes.
+	// If left empty, a single http listener on port 8008, serving the client
+	// and federation resources, is configured.
+	Listeners []SynapseHomeserverListener `json:"listeners,omitempty"`
+
+	// List of remote server domains for which federation metrics (age of PDUs
+	// sent and received) are reported. Only meaningful if Spec.Metrics is
+	// enabled.
+	FederationMetricsDomains []string `json:"federationMetricsDomains,omitempty"`
+
+	// +kubebuilder:validation:MinItems=3
+	// +kubebuilder:validation:MaxItems=3
+
+	// Thresholds of the Python garbage collector, for each of its three
+	// generations. Tuning them can reduce the latency of large instances. If
+	// left empty, Synapse's default applies.
+	GCThresholds []int `json:"gcThresholds,omitempty"`
+
+	// Flags to enable Prometheus metrics which are not suitable to be enabled
+	// by default
+	MetricsFlags *SynapseHomeserverMetricsFlags `json:"metricsFlags,omitempty"`
+
+	// Media repository options
+	Media *SynapseHomeserverMedia `json:"media,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+[KM]?$`
+
+	// Largest allowed upload size, in bytes or with a K or M suffix (e.g.
+	// '100M'), rendered into 'max_upload_size'. The Ingress or reverse
+	// proxy in front of Synapse may enforce its own limit. If left empty,
+	// Synapse's default (50M) applies.
+	MaxUploadSize string `json:"maxUploadSize,omitempty"`
+
+	// +kubebuilder:validation:Enum="1";"2";"3";"4";"5";"6";"7";"8";"9";"10"
+
+	// Room version of the newly created rooms, rendered into
+	// 'default_room_version'. Must be one of the stable room versions of
+	// Synapse v1.71.0, from '1' to '10'. If left empty, Synapse's default
+	// applies.
+	DefaultRoomVersion string `json:"defaultRoomVersion,omitempty"`
+
+	// Rate limits applied to the clients, rendered into the 'rc_message',
+	// 'rc_registration' and 'rc_login' sections. Omitted rate limits keep
+	// Synapse's defaults.
+	RateLimiting *SynapseHomeserverRateLimiting `json:"rateLimiting,omitempty"`
+
+	// Server-wide message retention policy, rendered into the 'retention'
+	// section. Expired events are purged by background jobs.
+	Retention *SynapseHomeserverRetention `json:"retention,omitempty"`
+
+	// Configuration of the previews generated for URLs posted in rooms
+	URLPreview *SynapseHomeserverURLPreview `json:"urlPreview,omitempty"`
+
+	// Servers trusted to provide the signing keys of other servers, rendered
+	// into 'trusted_key_servers'. Useful for private federations. If left
+	// empty, matrix.org is used.
+	TrustedKeyServers []SynapseHomeserverKeyServer `json:"trustedKeyServers,omitempty"`
+
+	// Set to true to suppress the warning emitted on start-up when
+	// matrix.org is one of the trusted key servers, rendered into
+	// 'suppress_key_server_warning'
+	SuppressKeyServerWarning bool `json:"suppressKeyServerWarning,omitempty"`
+
+	// Debugging-only manhole listener, giving access to a Python shell in the
+	// running Synapse process. It is only reachable with 'kubectl
+	// port-forward'.
+	Manhole *SynapseHomeserverManhole `json:"manhole,omitempty"`
+
+	// Dedicated listener serving the admin API (/_synapse/admin), only
+	// reachable in the cluster. The admin API is then blocked on the other
+	// listeners, including the one on port 8008 routed by the Ingress.
+	AdminListener *SynapseHomeserverAdminListener `json:"adminListener,omitempty"`
+
+	// List of Synapse modules to load, rendered into the 'modules' section of
+	// homeserver.yaml. Modules are the way to load extensions such as spam
+	// checkers or password providers.
+	Modules []SynapseHomeserverModule `json:"modules,omitempty"`
+
+	// Set to true to allow anyone to register an account, rendered into
+	// 'enable_registration'. Synapse refuses to start with open registration
+	// unless a verification method, such as Captcha, is configured.
+	EnableRegistration bool `json:"enableRegistration,omitempty"`
+
+	// Set to false to disable the registration of users with the
+	// registration_shared_secret, e.g. with the register_new_matrix_user
+	// script. No shared secret is then generated nor configured, and initial
+	// rooms can't be created. If left empty, shared-secret registration is
+	// enabled.
+	SharedSecretRegistration *bool `json:"sharedSecretRegistration,omitempty"`
+
+	// Set to false to disable presence tracking, rendered into
+	// 'use_presence'. Presence is expensive on large instances. If left
+	// empty, presence is enabled.
+	EnablePresence *bool `json:"enablePresence,omitempty"`
+
+	// reCAPTCHA verification of the registrations. Requires
+	// EnableRegistration.
+	Captcha *SynapseHomeserverCaptcha `json:"captcha,omitempty"`
+
+	// Outgoing email configuration, rendered into the 'email' section.
+	// Required to reset passwords and to send notifications by email.
+	Email *SynapseHomeserverEmail `json:"email,omitempty"`
+
+	// Existing TURN server used for VoIP, rendered into the 'turn_uris',
+	// 'turn_shared_secret' and 'turn_user_lifetime' settings. Cannot be
+	// combined with Spec.TURN.Deploy.
+	TURN *SynapseHomeserverTURN `json:"turn,omitempty"`
+
+	// Set to false to disable logging in with a password, e.g. when users
+	// must log in through an OIDC identity provider. Written into the
+	// 'password_config' section of homeserver.yaml. If left empty, Synapse's
+	// default (enabled) applies.
+	PasswordLogin *bool `json:"passwordLogin,omitempty"`
+
+	// Enable OpenID Connect (OIDC) / OAuth 2.0 for registration and login.
+	// Written into the 'oidc_config' section of homeserver.yaml.
+	OIDC *SynapseHomeserverOIDC `json:"oidc,omitempty"`
+
+	// +listType=map
+	// +listMapKey=idpID
+
+	// List of OpenID Connect (OIDC) identity providers, written into the
+	// 'oidc_providers' section of homeserver.yaml. Use this instead of OIDC
+	// to offer several identity providers. Each provider must have a unique
+	// IdpID.
+	OIDCProviders []SynapseHomeserverOIDCProvider `json:"oidcProviders,omitempty"`
+
+	// Experimental features to enable or disable, rendered into the
+	// 'experimental_features' section of homeserver.yaml. Keys are the MSC
+	// flags known to the running Synapse version, e.g. 'msc3440_enabled'.
+	// If left empty, the section is omitted.
+	ExperimentalFeatures map[string]bool `json:"experimentalFeatures,omitempty"`
+}
+
+type SynapseHomeserverOIDCProvider struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._~-]+$`
+	// +kubebuilder:validation:MaxLength=250
+
+	// Unique identifier of the identity provider, used internally by
+	// Synapse and in the login URLs
+	IdpID string `json:"idpID"`
+
+	// User-facing name of the identity provider, shown on the login page
+	IdpName string `json:"idpName,omitempty"`
+
+	SynapseHomeserverOIDC `json:",inline"`
+}
+
+type SynapseHomeserverOIDC struct {
+	// +kubebuilder:validation:Required
+
+	// The OIDC issuer. Used to validate tokens and to discover the
+	// provider's endpoints.
+	Issuer string `json:"issuer"`
+
+	// +kubebuilder:validation:Required
+
+	// OAuth2 client ID
+	ClientID string `json:"clientID"`
+
+	// Name of a Secret holding the OAuth2 client secret in its
+	// 'client_secret' key. The client secret is only written in the
+	// homeserver secrets file.
+	ClientSecretName string `json:"clientSecretName,omitempty"`
+
+	// List of scopes to request. This should normally include the "openid"
+	// scope. If left empty, Synapse's default (["openid"]) applies.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+
+	// Name of the claim containing a unique identifier for the user. If left
+	// empty, Synapse's default ("sub") applies.
+	SubjectClaim string `json:"subjectClaim,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+
+	// Jinja2 template for the localpart of the MXID, e.g.
+	// "{{ user.preferred_username }}". If left empty, the user is prompted to
+	// choose their own username.
+	LocalpartTemplate string `json:"localpartTemplate,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+
+	// Jinja2 template for the display name to set on first login, e.g.
+	// "{{ user.given_name }} {{ user.last_name }}". If left empty, no display
+	// name is set.
+	DisplayNameTemplate string `json:"displayNameTemplate,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Allow a user logging in via OIDC to match a pre-existing account
+	// instead of failing. Useful when migrating from password logins to OIDC.
+	AllowExistingUsers bool `json:"allowExistingUsers,omitempty"`
+}
+
+type SynapseHomeserverListener struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+
+	// Port on which the listener listens
+	Port int `json:"port"`
+
+	// +kubebuilder:default:="http"
+	// +kubebuilder:validation:Enum=http;metrics;manhole
+
+	// Type of the listener
+	Type string `json:"type,omitempty"`
+
+	// Local addresses to listen on. If left empty, Synapse's default (all
+	// interfaces) applies.
+	BindAddresses []string `json:"bindAddresses,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Whether the listener should trust the X-Forwarded-For header to
+	// determine the client IP address. Only set to true when Synapse is
+	// actually behind a trusted reverse proxy which sets this header.
+	// Otherwise, clients are able to spoof their IP address, and rate limits
+	// may apply to the proxy IP address instead of the client's.
+	XForwarded bool `json:"xForwarded,omitempty"`
+
+	// List of HTTP resources to serve on this listener. Only used by http
+	// listeners.
+	Resources []SynapseHomeserverListenerResource `json:"resources,omitempty"`
+}
+
+type SynapseHomeserverListenerResource struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+
+	// Names of the resources to serve
+	Names []SynapseHomeserverListenerResourceName `json:"names"`
+
+	// +kubebuilder:default:=false
+
+	// Whether Synapse should compress HTTP responses to clients that support
+	// it
+	Compress bool `json:"compress,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=client;consent;federation;keys;media;metrics;openid;replication;static;health
+type SynapseHomeserverListenerResourceName string
+
+type SynapseHomeserverMetricsFlags struct {
+	// +kubebuilder:default:=false
+
+	// Publish synapse_federation_known_servers, a gauge of the number of
+	// servers this homeserver knows about, including itself. May cause
+	// performance problems on large homeservers.
+	KnownServers bool `json:"knownServers,omitempty"`
+}
+
+type SynapseHomeserverModule struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)+$`
+
+	// Python path of the module class, e.g. my_module.MyModule
+	Module string `json:"module"`
+
+	// +kubebuilder:pruning:PreserveUnknownFields
+
+	// Configuration of the module, passed as is to the module
+	Config *runtime.RawExtension `json:"config,omitempty"`
+
+	// Name of a ConfigMap, in the Synapse namespace, holding the Python
+	// sources of the module. It is mounted in the Synapse container and
+	// added to the PYTHONPATH. Cannot be combined with
+	// PersistentVolumeClaim. Not needed for modules shipped with the Synapse
+	// image.
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Name of a PVC, in the Synapse namespace, holding the Python package of
+	// the module. It is mounted read-only in the Synapse container and added
+	// to the PYTHONPATH. Cannot be combined with ConfigMap.
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+}
+
+// SynapseHomeserverCaptcha configures the reCAPTCHA verification of the
+// registrations. The keys are only written in the homeserver secrets file,
+// never in the homeserver.yaml ConfigMap.
+type SynapseHomeserverCaptcha struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to require a captcha to be answered on registration,
+	// rendered into 'enable_registration_captcha'
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Required
+
+	// Name of a Secret, living in the Synapse namespace, holding the
+	// recaptcha_public_key and recaptcha_private_key keys
+	SecretName string `json:"secretName"`
+}
+
+// SynapseHomeserverEmail configures the sending of emails by Synapse. The
+// SMTP credentials are only written in the homeserver secrets file, never in
+// the homeserver.yaml ConfigMap.
+type SynapseHomeserverEmail struct {
+	// +kubebuilder:default:=false
+
+	// Whether to add an email section to homeserver.yaml
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Required
+
+	// Hostname of the outgoing SMTP server
+	SMTPHost string `json:"smtpHost"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default:=25
+
+	// Port of the outgoing SMTP server
+	SMTPPort int `json:"smtpPort,omitempty"`
+
+	// Name of a Secret, living in the Synapse namespace, holding the
+	// smtp_user and smtp_pass keys. If left empty, no authentication is
+	// attempted.
+	SecretName string `json:"secretName,omitempty"`
+
+	// +kubebuilder:default:=false
+
+	// Set to true to refuse to connect to an SMTP server which doesn't
+	// support STARTTLS, rendered into 'require_transport_security'
+	RequireTransportSecurity bool `json:"requireTransportSecurity,omitempty"`
+
+	// The "From" address of the emails, e.g.
+	// "Your Friendly %(app)s homeserver <noreply@example.com>". Required
+	// when email is enabled.
+	NotifFrom string `json:"notifFrom,omitempty"`
+
+	// Value of the '%(app)s' placeholder in NotifFrom and in the email
+	// subjects. If left empty, Synapse's default ("Matrix") applies.
+	AppName string `json:"appName,omitempty"`
+}
+
+// SynapseHomeserverTURN configures an existing TURN server. The shared secret
+// is only written in the homeserver secrets file, never in the
+// homeserver.yaml ConfigMap.
+type SynapseHomeserverTURN struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+
+	// URIs of the TURN server, with a turn: or turns: scheme, e.g.
+	// "turn:turn.example.com:3478?transport=udp"
+	URIs []string `json:"uris"`
+
+	// +kubebuilder:validation:Required
+
+	// Name of a Secret, living in the Synapse namespace, holding the
+	// turn_shared_secret key
+	SecretName string `json:"secretName"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Lifetime of the TURN credentials handed to the clients, as a Synapse
+	// duration such as '1h'. If left empty, Synapse's default ("1h")
+	// applies.
+	UserLifetime string `json:"userLifetime,omitempty"`
+}
+
+// SynapseHomeserverManhole configures the manhole listener. The listener is
+// bound to localhost and never exposed through the Synapse Service: it is
+// only reachable with 'kubectl port-forward'. It is meant for live debugging
+// only, and uses the default Synapse manhole credentials.
+type SynapseHomeserverManhole struct {
+	// +kubebuilder:default:=false
+
+	// Whether to add a manhole listener to homeserver.yaml
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default:=9010
+
+	// Port of the manhole listener, on localhost. Must not conflict with the
+	// other listeners.
+	Port int `json:"port,omitempty"`
+}
+
+// SynapseHomeserverAdminListener configures a dedicated http listener for the
+// admin API. It is exposed on the 'admin' port of the Synapse Service, which
+// is only reachable in the cluster. The other http listeners answer
+// /_synapse/admin with a 404, and the Ingress managed by the operator never
+// routes it, so that admin tools must go through this port.
+type SynapseHomeserverAdminListener struct {
+	// +kubebuilder:default:=false
+
+	// Whether to add the admin listener to homeserver.yaml
+	Enabled bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default:=8090
+
+	// Port of the admin listener. Must not conflict with the other
+	// listeners.
+	Port int `json:"port,omitempty"`
+}
+
+type SynapseHomeserverKeyServer struct {
+	// +kubebuilder:validation:Required
+
+	// Name of the trusted key server
+	ServerName string `json:"serverName"`
+
+	// Map from key ID (e.g. 'ed25519:auto') to base64-encoded public key. If
+	// set, the responses of the key server must be signed by at least one of
+	// these keys.
+	VerifyKeys map[string]string `json:"verifyKeys,omitempty"`
+}
+
+type SynapseHomeserverRateLimiting struct {
+	// Rate limit of the messages sent by each account ('rc_message')
+	Message *SynapseHomeserverRateLimit `json:"message,omitempty"`
+
+	// Rate limit of the registration requests of each client IP address
+	// ('rc_registration')
+	Registration *SynapseHomeserverRateLimit `json:"registration,omitempty"`
+
+	// Rate limits of the login requests ('rc_login')
+	Login *SynapseHomeserverLoginRateLimiting `json:"login,omitempty"`
+}
+
+type SynapseHomeserverLoginRateLimiting struct {
+	// Rate limit based on the client IP address ('address')
+	Address *SynapseHomeserverRateLimit `json:"address,omitempty"`
+
+	// Rate limit based on the account being logged into ('account')
+	Account *SynapseHomeserverRateLimit `json:"account,omitempty"`
+
+	// Rate limit based on the failed login attempts of the account being
+	// logged into ('failed_attempts')
+	FailedAttempts *SynapseHomeserverRateLimit `json:"failedAttempts,omitempty"`
+}
+
+type SynapseHomeserverRateLimit struct {
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+
+	// Number of requests per second allowed once the burst is exhausted,
+	// e.g. "0.2" ('per_second'). If left empty, Synapse's default applies.
+	PerSecond string `json:"perSecond,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+
+	// Number of requests allowed in a burst ('burst_count'). If left empty,
+	// Synapse's default applies.
+	BurstCount *int `json:"burstCount,omitempty"`
+}
+
+type SynapseHomeserverRetention struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to enable the message retention policies. If false, the
+	// 'retention' section is left out of homeserver.yaml.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Retention policy applied to the rooms without an 'm.room.retention'
+	// state event
+	DefaultPolicy *SynapseHomeserverRetentionPolicy `json:"defaultPolicy,omitempty"`
+
+	// Background jobs purging the expired events. If left empty, Synapse
+	// purges the expired events of every room daily.
+	PurgeJobs []SynapseHomeserverRetentionPurgeJob `json:"purgeJobs,omitempty"`
+}
+
+type SynapseHomeserverRetentionPolicy struct {
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Minimum lifetime of the events, as a Synapse duration such as '1d'
+	MinLifetime string `json:"minLifetime,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Maximum lifetime of the events, as a Synapse duration such as '1y'.
+	// Must not be lower than MinLifetime.
+	MaxLifetime string `json:"maxLifetime,omitempty"`
+}
+
+type SynapseHomeserverRetentionPurgeJob struct {
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Only purge the rooms whose max_lifetime is higher than this duration
+	ShortestMaxLifetime string `json:"shortestMaxLifetime,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Only purge the rooms whose max_lifetime is lower than or equal to this
+	// duration
+	LongestMaxLifetime string `json:"longestMaxLifetime,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Interval between two runs of the job, such as '12h'
+	Interval string `json:"interval"`
+}
+
+type SynapseHomeserverURLPreview struct {
+	// +kubebuilder:default:=false
+
+	// Set to true to enable URL previews, rendered into
+	// 'url_preview_enabled'
+	Enabled bool `json:"enabled,omitempty"`
+
+	// List of IP address CIDR ranges that the URL preview spider is denied
+	// from accessing, rendered into 'url_preview_ip_range_blacklist'. If
+	// left empty while URL previews are enabled, the private, loopback and
+	// reserved ranges recommended by Synapse are used.
+	IPRangeBlacklist []string `json:"ipRangeBlacklist,omitempty"`
+}
+
+type SynapseHomeserverMedia struct {
+	// +kubebuilder:default:=false
+
+	// Whether to generate new thumbnails on the fly to precisely match the
+	// resolution requested by the client. If false, Synapse picks a
+	// thumbnail from the precalculated list defined in ThumbnailSizes.
+	DynamicThumbnails bool `json:"dynamicThumbnails,omitempty"`
+
+	// List of thumbnails to precalculate when an image is uploaded. If left
+	// empty, the Synapse defaults are used.
+	ThumbnailSizes []SynapseHomeserverMediaThumbnailSize `json:"thumbnailSizes,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Duration after which the media uploaded by local users are deleted if
+	// they haven't been accessed, as a Synapse duration such as '90d',
+	// rendered into the 'media_retention' section. If left empty, local
+	// media are kept forever.
+	LocalMediaLifetime string `json:"localMediaLifetime,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ms|s|m|h|d|w|y)?$`
+
+	// Duration after which the cached remote media are deleted if they
+	// haven't been accessed, as a Synapse duration such as '14d', rendered
+	// into the 'media_retention' section. They are downloaded again from
+	// the remote server when needed. If left empty, remote media are cached
+	// forever.
+	RemoteMediaLifetime string `json:"remoteMediaLifetime,omitempty"`
+}
+
+type SynapseHomeserverMediaThumbnailSize struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+
+	// Width of the thumbnail, in pixels
+	Width int `json:"width"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+
+	// Height of the thumbnail, in pixels
+	Height int `json:"height"`
+
+	// +kubebuilder:validation:Enum=crop;scale
+	// +kubebuilder:default:=scale
+
+	// Thumbnailing method. 'crop' returns a thumbnail of exactly the given
+	// size, 'scale' keeps the aspect ratio of the original image and fits it
+	// within the given size.
+	Method string `json:"method,omitempty"`
+}
+
+// SynapseHomeserverDirectoryRule defines a rule for the alias_creation_rules
+// and room_list_publication_rules sections of homeserver.yaml. Missing globs
+// default to "*".
+type SynapseHomeserverDirectoryRule struct {
+	// Glob matching against the user ID of the requester
+	UserID string `json:"userID,omitempty"`
+
+	// Glob matching against the alias being created or published
+	Alias string `json:"alias,omitempty"`
+
+	// Glob matching against the room ID
+	RoomID string `json:"roomID,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=allow;deny
+
+	// Whether to allow or deny the request if the rule matches
+	Action string `json:"action"`
 }
 
 // SynapseStatus defines the observed state of Synapse
@@ -96,6 +1281,15 @@ type SynapseStatus struct {
 	// Information on the bridges deployed alongside Synapse
 	Bridges SynapseStatusBridges `json:"bridges,omitempty"`
 
+	// The public-facing domain of the server. Matrix user IDs on this server
+	// have the form @user:<serverName>
+	ServerName string `json:"serverName,omitempty"`
+
+	// URL that Matrix clients should use to connect to the homeserver. It is
+	// the public_baseurl if configured in homeserver.yaml, otherwise the
+	// in-cluster URL of the Synapse Service.
+	ClientBaseURL string `json:"clientBaseURL,omitempty"`
+
 	// State of the Synapse instance
 	State string `json:"state,omitempty"`
 
@@ -103,7 +1297,68 @@ type SynapseStatus struct {
 	Reason string `json:"reason,omitempty"`
 
 	// +kubebuilder:default:=false
+
+	// Set to true when a new reconciliation of Synapse has been requested,
+	// either by a bridge or by the user through the
+	// synapse.opdev.io/force-reconcile annotation. Reset to false once the
+	// reconciliation completes.
 	NeedsReconcile bool `json:"needsReconcile,omitempty"`
+
+	// Time at which the last forced reconciliation, requested through the
+	// synapse.opdev.io/force-reconcile annotation, was processed
+	LastForcedReconcile string `json:"lastForcedReconcile,omitempty"`
+
+	// Time at which the macaroon secret key was last rotated, through the
+	// synapse.opdev.io/rotate-macaroon-secret-key annotation
+	LastMacaroonSecretKeyRotation string `json:"lastMacaroonSecretKeyRotation,omitempty"`
+
+	// Hash of the data of the user-provided ConfigMap, defined in
+	// Spec.Homeserver.ConfigMap, as last copied by the operator. Used to
+	// detect modifications of the ConfigMap.
+	InputConfigMapHash string `json:"inputConfigMapHash,omitempty"`
+
+	// Reference to the Secret holding the registration_shared_secret
+	// generated by the operator. It can be used to register new users, for
+	// instance with the register_new_matrix_user script. Only set if the
+	// homeserver.yaml is created from Spec.Homeserver.Values, and
+	// shared-secret registration isn't disabled.
+	RegistrationSharedSecretRef *SynapseStatusSecretKeyRef `json:"registrationSharedSecretRef,omitempty"`
+
+	// Result of the last self-test of the /.well-known/matrix/server
+	// endpoint. Only set if Spec.WellKnown is enabled.
+	ServerWellKnown *SynapseStatusServerWellKnown `json:"serverWellKnown,omitempty"`
+
+	// Resources created and managed by the operator for this Synapse
+	// instance, in the Synapse namespace. They are owned by the Synapse
+	// object and garbage collected when it is deleted.
+	ManagedResources []SynapseStatusManagedResource `json:"managedResources,omitempty"`
+}
+
+type SynapseStatusServerWellKnown struct {
+	// State of the /.well-known/matrix/server endpoint, either OK or FAILED
+	State string `json:"state,omitempty"`
+
+	// Reason for a FAILED State
+	Reason string `json:"reason,omitempty"`
+
+	// Server name and port advertised in the m.server field
+	Server string `json:"server,omitempty"`
+}
+
+type SynapseStatusManagedResource struct {
+	// Kind of the resource
+	Kind string `json:"kind"`
+
+	// Name of the resource
+	Name string `json:"name"`
+}
+
+type SynapseStatusSecretKeyRef struct {
+	// Name of the Secret, in the Synapse namespace
+	Name string `json:"name,omitempty"`
+
+	// Key of the Secret holding the value
+	Key string `json:"key,omitempty"`
 }
 
 type SynapseStatusBridges struct {
@@ -145,8 +1400,11 @@ type SynapseStatusDatabaseConnectionInfo struct {
 	// Base64 encoded password
 	Password string `json:"password,omitempty"`
 
-	// State of the PostgreSQL database
+	// State of the PostgreSQL database: NOT READY, PENDING, READY or FAILED
 	State string `json:"State,omitempty"`
+
+	// Reason for the current state of the PostgreSQL database, if not READY
+	Reason string `json:"reason,omitempty"`
 }
 
 type SynapseStatusHomeserverConfiguration struct {
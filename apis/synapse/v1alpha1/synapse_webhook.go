@@ -0,0 +1,143 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var synapselog = logf.Log.WithName("synapse-resource")
+
+func (r *Synapse) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-synapse-opdev-io-v1alpha1-synapse,mutating=false,failurePolicy=fail,sideEffects=None,groups=synapse.opdev.io,resources=synapses,verbs=create;update,versions=v1alpha1,name=vsynapse.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Synapse{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Synapse) ValidateCreate() error {
+	synapselog.Info("validate create", "name", r.Name)
+
+	return r.validateSynapse()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Synapse) ValidateUpdate(old runtime.Object) error {
+	synapselog.Info("validate update", "name", r.Name)
+
+	return r.validateSynapse()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Synapse) ValidateDelete() error {
+	return nil
+}
+
+func (r *Synapse) validateSynapse() error {
+	allErrs := ValidateAuthentication(
+		r.Spec.Homeserver.Values,
+		field.NewPath("spec", "homeserver", "values"),
+	)
+	allErrs = append(allErrs, ValidateStorage(
+		r.Spec.Storage,
+		field.NewPath("spec", "storage"),
+	)...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "Synapse"},
+		r.Name,
+		allErrs,
+	)
+}
+
+// ValidateAuthentication checks the authentication methods configured in
+// the given Values for conflicts, which would otherwise bring up a
+// homeserver nobody can log in to, or fail on startup.
+func ValidateAuthentication(values *SynapseHomeserverValues, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if values == nil {
+		return allErrs
+	}
+
+	isSSOConfigured := values.OIDC != nil || len(values.OIDCProviders) > 0
+	if values.PasswordLogin != nil && !*values.PasswordLogin && !isSSOConfigured {
+		allErrs = append(allErrs, field.Invalid(
+			fldPath.Child("passwordLogin"),
+			false,
+			"password login cannot be disabled if no OIDC identity provider is configured",
+		))
+	}
+
+	// Synapse registers the provider configured in oidc_config with the
+	// "oidc" IdP ID
+	if values.OIDC != nil {
+		for i, provider := range values.OIDCProviders {
+			if provider.IdpID == "oidc" {
+				allErrs = append(allErrs, field.Invalid(
+					fldPath.Child("oidcProviders").Index(i).Child("idpID"),
+					provider.IdpID,
+					"the oidc IdP ID is already used by the provider configured in oidc",
+				))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateStorage checks that the storage sizes in the given Storage are
+// valid quantities, such as 50Gi. A malformed size would otherwise only
+// surface as an obscure error when creating the PVC.
+func ValidateStorage(storage *SynapseStorage, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if storage == nil || storage.MediaStore == nil || storage.MediaStore.Size == "" {
+		return allErrs
+	}
+
+	sizePath := fldPath.Child("mediaStore", "size")
+	size, err := resource.ParseQuantity(storage.MediaStore.Size)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(
+			sizePath,
+			storage.MediaStore.Size,
+			"must be a quantity, such as 50Gi",
+		))
+	} else if size.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(
+			sizePath,
+			storage.MediaStore.Size,
+			"must be greater than 0",
+		))
+	}
+
+	return allErrs
+}
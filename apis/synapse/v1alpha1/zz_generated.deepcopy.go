@@ -22,16 +22,32 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BridgeStatusSynapseConnectivity) DeepCopyInto(out *BridgeStatusSynapseConnectivity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BridgeStatusSynapseConnectivity.
+func (in *BridgeStatusSynapseConnectivity) DeepCopy() *BridgeStatusSynapseConnectivity {
+	if in == nil {
+		return nil
+	}
+	out := new(BridgeStatusSynapseConnectivity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Heisenbridge) DeepCopyInto(out *Heisenbridge) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Heisenbridge.
@@ -67,6 +83,21 @@ func (in *HeisenbridgeConfigMap) DeepCopy() *HeisenbridgeConfigMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeisenbridgeIdentd) DeepCopyInto(out *HeisenbridgeIdentd) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeisenbridgeIdentd.
+func (in *HeisenbridgeIdentd) DeepCopy() *HeisenbridgeIdentd {
+	if in == nil {
+		return nil
+	}
+	out := new(HeisenbridgeIdentd)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HeisenbridgeList) DeepCopyInto(out *HeisenbridgeList) {
 	*out = *in
@@ -103,7 +134,24 @@ func (in *HeisenbridgeList) DeepCopyObject() runtime.Object {
 func (in *HeisenbridgeSpec) DeepCopyInto(out *HeisenbridgeSpec) {
 	*out = *in
 	out.ConfigMap = in.ConfigMap
+	if in.Identd != nil {
+		in, out := &in.Identd, &out.Identd
+		*out = new(HeisenbridgeIdentd)
+		**out = **in
+	}
 	out.Synapse = in.Synapse
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultRequests != nil {
+		in, out := &in.DefaultRequests, &out.DefaultRequests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeisenbridgeSpec.
@@ -119,6 +167,18 @@ func (in *HeisenbridgeSpec) DeepCopy() *HeisenbridgeSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HeisenbridgeStatus) DeepCopyInto(out *HeisenbridgeStatus) {
 	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SynapseConnectivity != nil {
+		in, out := &in.SynapseConnectivity, &out.SynapseConnectivity
+		*out = new(BridgeStatusSynapseConnectivity)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeisenbridgeStatus.
@@ -151,8 +211,8 @@ func (in *MautrixSignal) DeepCopyInto(out *MautrixSignal) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignal.
@@ -188,6 +248,56 @@ func (in *MautrixSignalConfigMap) DeepCopy() *MautrixSignalConfigMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalEncryption) DeepCopyInto(out *MautrixSignalEncryption) {
+	*out = *in
+	if in.KeySharing != nil {
+		in, out := &in.KeySharing, &out.KeySharing
+		*out = new(MautrixSignalEncryptionKeySharing)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalEncryption.
+func (in *MautrixSignalEncryption) DeepCopy() *MautrixSignalEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalEncryptionKeySharing) DeepCopyInto(out *MautrixSignalEncryptionKeySharing) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalEncryptionKeySharing.
+func (in *MautrixSignalEncryptionKeySharing) DeepCopy() *MautrixSignalEncryptionKeySharing {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalEncryptionKeySharing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalHomeserver) DeepCopyInto(out *MautrixSignalHomeserver) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalHomeserver.
+func (in *MautrixSignalHomeserver) DeepCopy() *MautrixSignalHomeserver {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalHomeserver)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MautrixSignalList) DeepCopyInto(out *MautrixSignalList) {
 	*out = *in
@@ -220,11 +330,134 @@ func (in *MautrixSignalList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalManagementRoomText) DeepCopyInto(out *MautrixSignalManagementRoomText) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalManagementRoomText.
+func (in *MautrixSignalManagementRoomText) DeepCopy() *MautrixSignalManagementRoomText {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalManagementRoomText)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalRelay) DeepCopyInto(out *MautrixSignalRelay) {
+	*out = *in
+	if in.MessageFormats != nil {
+		in, out := &in.MessageFormats, &out.MessageFormats
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalRelay.
+func (in *MautrixSignalRelay) DeepCopy() *MautrixSignalRelay {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalRelay)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalShutdown) DeepCopyInto(out *MautrixSignalShutdown) {
+	*out = *in
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalShutdown.
+func (in *MautrixSignalShutdown) DeepCopy() *MautrixSignalShutdown {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalShutdown)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MautrixSignalSpec) DeepCopyInto(out *MautrixSignalSpec) {
 	*out = *in
 	out.ConfigMap = in.ConfigMap
 	out.Synapse = in.Synapse
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(MautrixSignalEncryption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ManagementRoomText != nil {
+		in, out := &in.ManagementRoomText, &out.ManagementRoomText
+		*out = new(MautrixSignalManagementRoomText)
+		**out = **in
+	}
+	if in.Relay != nil {
+		in, out := &in.Relay, &out.Relay
+		*out = new(MautrixSignalRelay)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Homeserver != nil {
+		in, out := &in.Homeserver, &out.Homeserver
+		*out = new(MautrixSignalHomeserver)
+		**out = **in
+	}
+	if in.SetHome != nil {
+		in, out := &in.SetHome, &out.SetHome
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(MautrixSignalStorage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultRequests != nil {
+		in, out := &in.DefaultRequests, &out.DefaultRequests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Shutdown != nil {
+		in, out := &in.Shutdown, &out.Shutdown
+		*out = new(MautrixSignalShutdown)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalSpec.
@@ -241,6 +474,18 @@ func (in *MautrixSignalSpec) DeepCopy() *MautrixSignalSpec {
 func (in *MautrixSignalStatus) DeepCopyInto(out *MautrixSignalStatus) {
 	*out = *in
 	out.Synapse = in.Synapse
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SynapseConnectivity != nil {
+		in, out := &in.SynapseConnectivity, &out.SynapseConnectivity
+		*out = new(BridgeStatusSynapseConnectivity)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalStatus.
@@ -268,6 +513,26 @@ func (in *MautrixSignalStatusSynapse) DeepCopy() *MautrixSignalStatusSynapse {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MautrixSignalStorage) DeepCopyInto(out *MautrixSignalStorage) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MautrixSignalStorage.
+func (in *MautrixSignalStorage) DeepCopy() *MautrixSignalStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(MautrixSignalStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MautrixSignalSynapseSpec) DeepCopyInto(out *MautrixSignalSynapseSpec) {
 	*out = *in
@@ -289,7 +554,7 @@ func (in *Synapse) DeepCopyInto(out *Synapse) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Synapse.
@@ -311,199 +576,1315 @@ func (in *Synapse) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseHomeserver) DeepCopyInto(out *SynapseHomeserver) {
+func (in *SynapseBridges) DeepCopyInto(out *SynapseBridges) {
 	*out = *in
-	if in.ConfigMap != nil {
-		in, out := &in.ConfigMap, &out.ConfigMap
-		*out = new(SynapseHomeserverConfigMap)
-		**out = **in
+	if in.Heisenbridge != nil {
+		in, out := &in.Heisenbridge, &out.Heisenbridge
+		*out = new(SynapseBridgesHeisenbridge)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Values != nil {
-		in, out := &in.Values, &out.Values
-		*out = new(SynapseHomeserverValues)
-		**out = **in
+	if in.MautrixSignal != nil {
+		in, out := &in.MautrixSignal, &out.MautrixSignal
+		*out = new(SynapseBridgesMautrixSignal)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserver.
-func (in *SynapseHomeserver) DeepCopy() *SynapseHomeserver {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseBridges.
+func (in *SynapseBridges) DeepCopy() *SynapseBridges {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseHomeserver)
+	out := new(SynapseBridges)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseHomeserverConfigMap) DeepCopyInto(out *SynapseHomeserverConfigMap) {
+func (in *SynapseBridgesHeisenbridge) DeepCopyInto(out *SynapseBridgesHeisenbridge) {
 	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(HeisenbridgeConfigMap)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverConfigMap.
-func (in *SynapseHomeserverConfigMap) DeepCopy() *SynapseHomeserverConfigMap {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseBridgesHeisenbridge.
+func (in *SynapseBridgesHeisenbridge) DeepCopy() *SynapseBridgesHeisenbridge {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseHomeserverConfigMap)
+	out := new(SynapseBridgesHeisenbridge)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseHomeserverValues) DeepCopyInto(out *SynapseHomeserverValues) {
+func (in *SynapseBridgesMautrixSignal) DeepCopyInto(out *SynapseBridgesMautrixSignal) {
 	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(MautrixSignalConfigMap)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverValues.
-func (in *SynapseHomeserverValues) DeepCopy() *SynapseHomeserverValues {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseBridgesMautrixSignal.
+func (in *SynapseBridgesMautrixSignal) DeepCopy() *SynapseBridgesMautrixSignal {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseHomeserverValues)
+	out := new(SynapseBridgesMautrixSignal)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseList) DeepCopyInto(out *SynapseList) {
+func (in *SynapseDatabase) DeepCopyInto(out *SynapseDatabase) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Synapse, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.PGBackRest != nil {
+		in, out := &in.PGBackRest, &out.PGBackRest
+		*out = new(SynapseDatabasePGBackRest)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseList.
-func (in *SynapseList) DeepCopy() *SynapseList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseDatabase.
+func (in *SynapseDatabase) DeepCopy() *SynapseDatabase {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseList)
+	out := new(SynapseDatabase)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *SynapseList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseDatabasePGBackRest) DeepCopyInto(out *SynapseDatabasePGBackRest) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(SynapseDatabasePGBackRestS3)
+		**out = **in
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseDatabasePGBackRest.
+func (in *SynapseDatabasePGBackRest) DeepCopy() *SynapseDatabasePGBackRest {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseDatabasePGBackRest)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseSpec) DeepCopyInto(out *SynapseSpec) {
+func (in *SynapseDatabasePGBackRestS3) DeepCopyInto(out *SynapseDatabasePGBackRestS3) {
 	*out = *in
-	in.Homeserver.DeepCopyInto(&out.Homeserver)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseSpec.
-func (in *SynapseSpec) DeepCopy() *SynapseSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseDatabasePGBackRestS3.
+func (in *SynapseDatabasePGBackRestS3) DeepCopy() *SynapseDatabasePGBackRestS3 {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseSpec)
+	out := new(SynapseDatabasePGBackRestS3)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatus) DeepCopyInto(out *SynapseStatus) {
+func (in *SynapseHomeserver) DeepCopyInto(out *SynapseHomeserver) {
 	*out = *in
-	out.DatabaseConnectionInfo = in.DatabaseConnectionInfo
-	out.HomeserverConfiguration = in.HomeserverConfiguration
-	out.Bridges = in.Bridges
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(SynapseHomeserverConfigMap)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(SynapseHomeserverValues)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatus.
-func (in *SynapseStatus) DeepCopy() *SynapseStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserver.
+func (in *SynapseHomeserver) DeepCopy() *SynapseHomeserver {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatus)
+	out := new(SynapseHomeserver)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatusBridges) DeepCopyInto(out *SynapseStatusBridges) {
+func (in *SynapseHomeserverAdminListener) DeepCopyInto(out *SynapseHomeserverAdminListener) {
 	*out = *in
-	out.Heisenbridge = in.Heisenbridge
-	out.MautrixSignal = in.MautrixSignal
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridges.
-func (in *SynapseStatusBridges) DeepCopy() *SynapseStatusBridges {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverAdminListener.
+func (in *SynapseHomeserverAdminListener) DeepCopy() *SynapseHomeserverAdminListener {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatusBridges)
+	out := new(SynapseHomeserverAdminListener)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatusBridgesHeisenbridge) DeepCopyInto(out *SynapseStatusBridgesHeisenbridge) {
+func (in *SynapseHomeserverCaptcha) DeepCopyInto(out *SynapseHomeserverCaptcha) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridgesHeisenbridge.
-func (in *SynapseStatusBridgesHeisenbridge) DeepCopy() *SynapseStatusBridgesHeisenbridge {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverCaptcha.
+func (in *SynapseHomeserverCaptcha) DeepCopy() *SynapseHomeserverCaptcha {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatusBridgesHeisenbridge)
+	out := new(SynapseHomeserverCaptcha)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatusBridgesMautrixSignal) DeepCopyInto(out *SynapseStatusBridgesMautrixSignal) {
+func (in *SynapseHomeserverConfigMap) DeepCopyInto(out *SynapseHomeserverConfigMap) {
 	*out = *in
+	if in.RolloutOnChange != nil {
+		in, out := &in.RolloutOnChange, &out.RolloutOnChange
+		*out = new(bool)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridgesMautrixSignal.
-func (in *SynapseStatusBridgesMautrixSignal) DeepCopy() *SynapseStatusBridgesMautrixSignal {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverConfigMap.
+func (in *SynapseHomeserverConfigMap) DeepCopy() *SynapseHomeserverConfigMap {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatusBridgesMautrixSignal)
+	out := new(SynapseHomeserverConfigMap)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatusDatabaseConnectionInfo) DeepCopyInto(out *SynapseStatusDatabaseConnectionInfo) {
+func (in *SynapseHomeserverDirectoryRule) DeepCopyInto(out *SynapseHomeserverDirectoryRule) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusDatabaseConnectionInfo.
-func (in *SynapseStatusDatabaseConnectionInfo) DeepCopy() *SynapseStatusDatabaseConnectionInfo {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverDirectoryRule.
+func (in *SynapseHomeserverDirectoryRule) DeepCopy() *SynapseHomeserverDirectoryRule {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatusDatabaseConnectionInfo)
+	out := new(SynapseHomeserverDirectoryRule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SynapseStatusHomeserverConfiguration) DeepCopyInto(out *SynapseStatusHomeserverConfiguration) {
+func (in *SynapseHomeserverEmail) DeepCopyInto(out *SynapseHomeserverEmail) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusHomeserverConfiguration.
-func (in *SynapseStatusHomeserverConfiguration) DeepCopy() *SynapseStatusHomeserverConfiguration {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverEmail.
+func (in *SynapseHomeserverEmail) DeepCopy() *SynapseHomeserverEmail {
 	if in == nil {
 		return nil
 	}
-	out := new(SynapseStatusHomeserverConfiguration)
+	out := new(SynapseHomeserverEmail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverKeyServer) DeepCopyInto(out *SynapseHomeserverKeyServer) {
+	*out = *in
+	if in.VerifyKeys != nil {
+		in, out := &in.VerifyKeys, &out.VerifyKeys
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverKeyServer.
+func (in *SynapseHomeserverKeyServer) DeepCopy() *SynapseHomeserverKeyServer {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverKeyServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverListener) DeepCopyInto(out *SynapseHomeserverListener) {
+	*out = *in
+	if in.BindAddresses != nil {
+		in, out := &in.BindAddresses, &out.BindAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]SynapseHomeserverListenerResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverListener.
+func (in *SynapseHomeserverListener) DeepCopy() *SynapseHomeserverListener {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverListener)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverListenerResource) DeepCopyInto(out *SynapseHomeserverListenerResource) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]SynapseHomeserverListenerResourceName, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverListenerResource.
+func (in *SynapseHomeserverListenerResource) DeepCopy() *SynapseHomeserverListenerResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverListenerResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverLoginRateLimiting) DeepCopyInto(out *SynapseHomeserverLoginRateLimiting) {
+	*out = *in
+	if in.Address != nil {
+		in, out := &in.Address, &out.Address
+		*out = new(SynapseHomeserverRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Account != nil {
+		in, out := &in.Account, &out.Account
+		*out = new(SynapseHomeserverRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailedAttempts != nil {
+		in, out := &in.FailedAttempts, &out.FailedAttempts
+		*out = new(SynapseHomeserverRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverLoginRateLimiting.
+func (in *SynapseHomeserverLoginRateLimiting) DeepCopy() *SynapseHomeserverLoginRateLimiting {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverLoginRateLimiting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverManhole) DeepCopyInto(out *SynapseHomeserverManhole) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverManhole.
+func (in *SynapseHomeserverManhole) DeepCopy() *SynapseHomeserverManhole {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverManhole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverMedia) DeepCopyInto(out *SynapseHomeserverMedia) {
+	*out = *in
+	if in.ThumbnailSizes != nil {
+		in, out := &in.ThumbnailSizes, &out.ThumbnailSizes
+		*out = make([]SynapseHomeserverMediaThumbnailSize, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverMedia.
+func (in *SynapseHomeserverMedia) DeepCopy() *SynapseHomeserverMedia {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverMedia)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverMediaThumbnailSize) DeepCopyInto(out *SynapseHomeserverMediaThumbnailSize) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverMediaThumbnailSize.
+func (in *SynapseHomeserverMediaThumbnailSize) DeepCopy() *SynapseHomeserverMediaThumbnailSize {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverMediaThumbnailSize)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverMetricsFlags) DeepCopyInto(out *SynapseHomeserverMetricsFlags) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverMetricsFlags.
+func (in *SynapseHomeserverMetricsFlags) DeepCopy() *SynapseHomeserverMetricsFlags {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverMetricsFlags)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverModule) DeepCopyInto(out *SynapseHomeserverModule) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverModule.
+func (in *SynapseHomeserverModule) DeepCopy() *SynapseHomeserverModule {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverOIDC) DeepCopyInto(out *SynapseHomeserverOIDC) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverOIDC.
+func (in *SynapseHomeserverOIDC) DeepCopy() *SynapseHomeserverOIDC {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverOIDC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverOIDCProvider) DeepCopyInto(out *SynapseHomeserverOIDCProvider) {
+	*out = *in
+	in.SynapseHomeserverOIDC.DeepCopyInto(&out.SynapseHomeserverOIDC)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverOIDCProvider.
+func (in *SynapseHomeserverOIDCProvider) DeepCopy() *SynapseHomeserverOIDCProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverOIDCProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverRateLimit) DeepCopyInto(out *SynapseHomeserverRateLimit) {
+	*out = *in
+	if in.BurstCount != nil {
+		in, out := &in.BurstCount, &out.BurstCount
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverRateLimit.
+func (in *SynapseHomeserverRateLimit) DeepCopy() *SynapseHomeserverRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverRateLimiting) DeepCopyInto(out *SynapseHomeserverRateLimiting) {
+	*out = *in
+	if in.Message != nil {
+		in, out := &in.Message, &out.Message
+		*out = new(SynapseHomeserverRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Registration != nil {
+		in, out := &in.Registration, &out.Registration
+		*out = new(SynapseHomeserverRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Login != nil {
+		in, out := &in.Login, &out.Login
+		*out = new(SynapseHomeserverLoginRateLimiting)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverRateLimiting.
+func (in *SynapseHomeserverRateLimiting) DeepCopy() *SynapseHomeserverRateLimiting {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverRateLimiting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverRetention) DeepCopyInto(out *SynapseHomeserverRetention) {
+	*out = *in
+	if in.DefaultPolicy != nil {
+		in, out := &in.DefaultPolicy, &out.DefaultPolicy
+		*out = new(SynapseHomeserverRetentionPolicy)
+		**out = **in
+	}
+	if in.PurgeJobs != nil {
+		in, out := &in.PurgeJobs, &out.PurgeJobs
+		*out = make([]SynapseHomeserverRetentionPurgeJob, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverRetention.
+func (in *SynapseHomeserverRetention) DeepCopy() *SynapseHomeserverRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverRetentionPolicy) DeepCopyInto(out *SynapseHomeserverRetentionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverRetentionPolicy.
+func (in *SynapseHomeserverRetentionPolicy) DeepCopy() *SynapseHomeserverRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverRetentionPurgeJob) DeepCopyInto(out *SynapseHomeserverRetentionPurgeJob) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverRetentionPurgeJob.
+func (in *SynapseHomeserverRetentionPurgeJob) DeepCopy() *SynapseHomeserverRetentionPurgeJob {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverRetentionPurgeJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverTURN) DeepCopyInto(out *SynapseHomeserverTURN) {
+	*out = *in
+	if in.URIs != nil {
+		in, out := &in.URIs, &out.URIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverTURN.
+func (in *SynapseHomeserverTURN) DeepCopy() *SynapseHomeserverTURN {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverTURN)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverURLPreview) DeepCopyInto(out *SynapseHomeserverURLPreview) {
+	*out = *in
+	if in.IPRangeBlacklist != nil {
+		in, out := &in.IPRangeBlacklist, &out.IPRangeBlacklist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverURLPreview.
+func (in *SynapseHomeserverURLPreview) DeepCopy() *SynapseHomeserverURLPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverURLPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseHomeserverValues) DeepCopyInto(out *SynapseHomeserverValues) {
+	*out = *in
+	if in.AliasCreationRules != nil {
+		in, out := &in.AliasCreationRules, &out.AliasCreationRules
+		*out = make([]SynapseHomeserverDirectoryRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.RoomListPublicationRules != nil {
+		in, out := &in.RoomListPublicationRules, &out.RoomListPublicationRules
+		*out = make([]SynapseHomeserverDirectoryRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnableRoomListSearch != nil {
+		in, out := &in.EnableRoomListSearch, &out.EnableRoomListSearch
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowPublicRoomsOverFederation != nil {
+		in, out := &in.AllowPublicRoomsOverFederation, &out.AllowPublicRoomsOverFederation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowPublicRoomsWithoutAuth != nil {
+		in, out := &in.AllowPublicRoomsWithoutAuth, &out.AllowPublicRoomsWithoutAuth
+		*out = new(bool)
+		**out = **in
+	}
+	if in.XForwarded != nil {
+		in, out := &in.XForwarded, &out.XForwarded
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CompressResponses != nil {
+		in, out := &in.CompressResponses, &out.CompressResponses
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]SynapseHomeserverListener, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FederationMetricsDomains != nil {
+		in, out := &in.FederationMetricsDomains, &out.FederationMetricsDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GCThresholds != nil {
+		in, out := &in.GCThresholds, &out.GCThresholds
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetricsFlags != nil {
+		in, out := &in.MetricsFlags, &out.MetricsFlags
+		*out = new(SynapseHomeserverMetricsFlags)
+		**out = **in
+	}
+	if in.Media != nil {
+		in, out := &in.Media, &out.Media
+		*out = new(SynapseHomeserverMedia)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimiting != nil {
+		in, out := &in.RateLimiting, &out.RateLimiting
+		*out = new(SynapseHomeserverRateLimiting)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(SynapseHomeserverRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.URLPreview != nil {
+		in, out := &in.URLPreview, &out.URLPreview
+		*out = new(SynapseHomeserverURLPreview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrustedKeyServers != nil {
+		in, out := &in.TrustedKeyServers, &out.TrustedKeyServers
+		*out = make([]SynapseHomeserverKeyServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Manhole != nil {
+		in, out := &in.Manhole, &out.Manhole
+		*out = new(SynapseHomeserverManhole)
+		**out = **in
+	}
+	if in.AdminListener != nil {
+		in, out := &in.AdminListener, &out.AdminListener
+		*out = new(SynapseHomeserverAdminListener)
+		**out = **in
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make([]SynapseHomeserverModule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SharedSecretRegistration != nil {
+		in, out := &in.SharedSecretRegistration, &out.SharedSecretRegistration
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnablePresence != nil {
+		in, out := &in.EnablePresence, &out.EnablePresence
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Captcha != nil {
+		in, out := &in.Captcha, &out.Captcha
+		*out = new(SynapseHomeserverCaptcha)
+		**out = **in
+	}
+	if in.Email != nil {
+		in, out := &in.Email, &out.Email
+		*out = new(SynapseHomeserverEmail)
+		**out = **in
+	}
+	if in.TURN != nil {
+		in, out := &in.TURN, &out.TURN
+		*out = new(SynapseHomeserverTURN)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PasswordLogin != nil {
+		in, out := &in.PasswordLogin, &out.PasswordLogin
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(SynapseHomeserverOIDC)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OIDCProviders != nil {
+		in, out := &in.OIDCProviders, &out.OIDCProviders
+		*out = make([]SynapseHomeserverOIDCProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExperimentalFeatures != nil {
+		in, out := &in.ExperimentalFeatures, &out.ExperimentalFeatures
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseHomeserverValues.
+func (in *SynapseHomeserverValues) DeepCopy() *SynapseHomeserverValues {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseHomeserverValues)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseIngress) DeepCopyInto(out *SynapseIngress) {
+	*out = *in
+	if in.IngressClassName != nil {
+		in, out := &in.IngressClassName, &out.IngressClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Federation != nil {
+		in, out := &in.Federation, &out.Federation
+		*out = new(SynapseIngressFederation)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseIngress.
+func (in *SynapseIngress) DeepCopy() *SynapseIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseIngressFederation) DeepCopyInto(out *SynapseIngressFederation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseIngressFederation.
+func (in *SynapseIngressFederation) DeepCopy() *SynapseIngressFederation {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseIngressFederation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseInitialRoom) DeepCopyInto(out *SynapseInitialRoom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseInitialRoom.
+func (in *SynapseInitialRoom) DeepCopy() *SynapseInitialRoom {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseInitialRoom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseList) DeepCopyInto(out *SynapseList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Synapse, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseList.
+func (in *SynapseList) DeepCopy() *SynapseList {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SynapseList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseMediaWorker) DeepCopyInto(out *SynapseMediaWorker) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseMediaWorker.
+func (in *SynapseMediaWorker) DeepCopy() *SynapseMediaWorker {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseMediaWorker)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseMetrics) DeepCopyInto(out *SynapseMetrics) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseMetrics.
+func (in *SynapseMetrics) DeepCopy() *SynapseMetrics {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseMetrics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapsePerformance) DeepCopyInto(out *SynapsePerformance) {
+	*out = *in
+	if in.PerCacheFactors != nil {
+		in, out := &in.PerCacheFactors, &out.PerCacheFactors
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapsePerformance.
+func (in *SynapsePerformance) DeepCopy() *SynapsePerformance {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapsePerformance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseProbes) DeepCopyInto(out *SynapseProbes) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseProbes.
+func (in *SynapseProbes) DeepCopy() *SynapseProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseRedis) DeepCopyInto(out *SynapseRedis) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseRedis.
+func (in *SynapseRedis) DeepCopy() *SynapseRedis {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseRedis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseSecrets) DeepCopyInto(out *SynapseSecrets) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseSecrets.
+func (in *SynapseSecrets) DeepCopy() *SynapseSecrets {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseSecrets)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseService) DeepCopyInto(out *SynapseService) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseService.
+func (in *SynapseService) DeepCopy() *SynapseService {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseSpec) DeepCopyInto(out *SynapseSpec) {
+	*out = *in
+	in.Homeserver.DeepCopyInto(&out.Homeserver)
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = new(SynapseSecrets)
+		**out = **in
+	}
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(SynapseDatabase)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.DefaultRequests != nil {
+		in, out := &in.DefaultRequests, &out.DefaultRequests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.TURN != nil {
+		in, out := &in.TURN, &out.TURN
+		*out = new(SynapseTURN)
+		**out = **in
+	}
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(SynapseRedis)
+		**out = **in
+	}
+	if in.MediaWorker != nil {
+		in, out := &in.MediaWorker, &out.MediaWorker
+		*out = new(SynapseMediaWorker)
+		**out = **in
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(SynapseProbes)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(SynapseStorage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(SynapseService)
+		**out = **in
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(SynapseIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WellKnown != nil {
+		in, out := &in.WellKnown, &out.WellKnown
+		*out = new(SynapseWellKnown)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = new(SynapseMetrics)
+		**out = **in
+	}
+	if in.Performance != nil {
+		in, out := &in.Performance, &out.Performance
+		*out = new(SynapsePerformance)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Bridges != nil {
+		in, out := &in.Bridges, &out.Bridges
+		*out = new(SynapseBridges)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InitialRooms != nil {
+		in, out := &in.InitialRooms, &out.InitialRooms
+		*out = make([]SynapseInitialRoom, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseSpec.
+func (in *SynapseSpec) DeepCopy() *SynapseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatus) DeepCopyInto(out *SynapseStatus) {
+	*out = *in
+	out.DatabaseConnectionInfo = in.DatabaseConnectionInfo
+	out.HomeserverConfiguration = in.HomeserverConfiguration
+	out.Bridges = in.Bridges
+	if in.RegistrationSharedSecretRef != nil {
+		in, out := &in.RegistrationSharedSecretRef, &out.RegistrationSharedSecretRef
+		*out = new(SynapseStatusSecretKeyRef)
+		**out = **in
+	}
+	if in.ServerWellKnown != nil {
+		in, out := &in.ServerWellKnown, &out.ServerWellKnown
+		*out = new(SynapseStatusServerWellKnown)
+		**out = **in
+	}
+	if in.ManagedResources != nil {
+		in, out := &in.ManagedResources, &out.ManagedResources
+		*out = make([]SynapseStatusManagedResource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatus.
+func (in *SynapseStatus) DeepCopy() *SynapseStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusBridges) DeepCopyInto(out *SynapseStatusBridges) {
+	*out = *in
+	out.Heisenbridge = in.Heisenbridge
+	out.MautrixSignal = in.MautrixSignal
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridges.
+func (in *SynapseStatusBridges) DeepCopy() *SynapseStatusBridges {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusBridges)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusBridgesHeisenbridge) DeepCopyInto(out *SynapseStatusBridgesHeisenbridge) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridgesHeisenbridge.
+func (in *SynapseStatusBridgesHeisenbridge) DeepCopy() *SynapseStatusBridgesHeisenbridge {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusBridgesHeisenbridge)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusBridgesMautrixSignal) DeepCopyInto(out *SynapseStatusBridgesMautrixSignal) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusBridgesMautrixSignal.
+func (in *SynapseStatusBridgesMautrixSignal) DeepCopy() *SynapseStatusBridgesMautrixSignal {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusBridgesMautrixSignal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusDatabaseConnectionInfo) DeepCopyInto(out *SynapseStatusDatabaseConnectionInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusDatabaseConnectionInfo.
+func (in *SynapseStatusDatabaseConnectionInfo) DeepCopy() *SynapseStatusDatabaseConnectionInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusDatabaseConnectionInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusHomeserverConfiguration) DeepCopyInto(out *SynapseStatusHomeserverConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusHomeserverConfiguration.
+func (in *SynapseStatusHomeserverConfiguration) DeepCopy() *SynapseStatusHomeserverConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusHomeserverConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusManagedResource) DeepCopyInto(out *SynapseStatusManagedResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusManagedResource.
+func (in *SynapseStatusManagedResource) DeepCopy() *SynapseStatusManagedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusManagedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusSecretKeyRef) DeepCopyInto(out *SynapseStatusSecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusSecretKeyRef.
+func (in *SynapseStatusSecretKeyRef) DeepCopy() *SynapseStatusSecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusSecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStatusServerWellKnown) DeepCopyInto(out *SynapseStatusServerWellKnown) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStatusServerWellKnown.
+func (in *SynapseStatusServerWellKnown) DeepCopy() *SynapseStatusServerWellKnown {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStatusServerWellKnown)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStorage) DeepCopyInto(out *SynapseStorage) {
+	*out = *in
+	if in.MediaStore != nil {
+		in, out := &in.MediaStore, &out.MediaStore
+		*out = new(SynapseStorageMediaStore)
+		**out = **in
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStorage.
+func (in *SynapseStorage) DeepCopy() *SynapseStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseStorageMediaStore) DeepCopyInto(out *SynapseStorageMediaStore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseStorageMediaStore.
+func (in *SynapseStorageMediaStore) DeepCopy() *SynapseStorageMediaStore {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseStorageMediaStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseTURN) DeepCopyInto(out *SynapseTURN) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseTURN.
+func (in *SynapseTURN) DeepCopy() *SynapseTURN {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseTURN)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SynapseWellKnown) DeepCopyInto(out *SynapseWellKnown) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SynapseWellKnown.
+func (in *SynapseWellKnown) DeepCopy() *SynapseWellKnown {
+	if in == nil {
+		return nil
+	}
+	out := new(SynapseWellKnown)
 	in.DeepCopyInto(out)
 	return out
 }
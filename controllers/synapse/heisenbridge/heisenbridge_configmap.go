@@ -21,6 +21,7 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -46,7 +47,12 @@ func (r *HeisenbridgeReconciler) reconcileHeisenbridgeConfigMap(ctx context.Cont
 
 	objectMetaHeisenbridge := reconcile.SetObjectMeta(h.Name, h.Namespace, map[string]string{})
 
-	desiredConfigMap, err := r.configMapForHeisenbridge(h, objectMetaHeisenbridge)
+	asToken, hsToken, err := r.getHeisenbridgeTokens(ctx, h)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	desiredConfigMap, err := r.configMapForHeisenbridge(h, objectMetaHeisenbridge, asToken, hsToken)
 	if err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -63,13 +69,48 @@ func (r *HeisenbridgeReconciler) reconcileHeisenbridgeConfigMap(ctx context.Cont
 	return subreconciler.ContinueReconciling()
 }
 
-// configMapForSynapse returns a synapse ConfigMap object
-func (r *HeisenbridgeReconciler) configMapForHeisenbridge(h *synapsev1alpha1.Heisenbridge, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
+// getHeisenbridgeTokens returns the as_token and hs_token of the existing
+// Heisenbridge ConfigMap, so that they aren't rotated at each reconciliation.
+// New tokens are generated if the ConfigMap doesn't exist yet, or doesn't
+// hold them.
+func (r *HeisenbridgeReconciler) getHeisenbridgeTokens(ctx context.Context, h *synapsev1alpha1.Heisenbridge) (string, string, error) {
+	current := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: h.Name, Namespace: h.Namespace}, current); err == nil {
+		if registration, err := utils.LoadYAMLFileFromConfigMapData(*current, "heisenbridge.yaml"); err == nil {
+			asToken, _ := registration["as_token"].(string)
+			hsToken, _ := registration["hs_token"].(string)
+			if asToken != "" && hsToken != "" {
+				return asToken, hsToken, nil
+			}
+		}
+	} else if !k8serrors.IsNotFound(err) {
+		return "", "", err
+	}
+
+	asToken, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+	hsToken, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+	return asToken, hsToken, nil
+}
+
+// configMapForHeisenbridge returns a Heisenbridge ConfigMap object, holding
+// the registration file of the bridge with the given tokens
+func (r *HeisenbridgeReconciler) configMapForHeisenbridge(
+	h *synapsev1alpha1.Heisenbridge,
+	objectMeta metav1.ObjectMeta,
+	asToken string,
+	hsToken string,
+) (*corev1.ConfigMap, error) {
 	heisenbridgeYaml := `
 id: heisenbridge
 url: http://` + GetHeisenbridgeServiceFQDN(*h) + `:9898
-as_token: EUFqSPQusV4mXkPKbwdHyIhthELQ1Xf9S5lSEzTrrlb0uz0ZJRHhwEljT71ByObe
-hs_token: If6r2GGlsNN4MnoW3djToADNdq0JuIJ1WNM4rKHO73WuG5QvVubj1Q4JHrmQBcS6
+as_token: ` + asToken + `
+hs_token: ` + hsToken + `
 rate_limited: false
 sender_localpart: heisenbridge
 namespaces:
@@ -209,6 +250,7 @@ func (r *HeisenbridgeReconciler) configureHeisenbridgeConfigMap(ctx context.Cont
 		h,
 		r.updateHeisenbridgeWithURL,
 		"heisenbridge.yaml",
+		nil,
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
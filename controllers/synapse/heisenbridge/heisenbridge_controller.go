@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,6 +38,10 @@ import (
 type HeisenbridgeReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Resource requests set on the containers without requests nor limits,
+	// unless overridden by Spec.DefaultRequests
+	DefaultRequests corev1.ResourceList
 }
 
 func GetHeisenbridgeServiceFQDN(h synapsev1alpha1.Heisenbridge) string {
@@ -46,6 +51,7 @@ func GetHeisenbridgeServiceFQDN(h synapsev1alpha1.Heisenbridge) string {
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=heisenbridges,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=heisenbridges/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=heisenbridges/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -66,8 +72,9 @@ func (r *HeisenbridgeReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	var subreconcilersForHeisenbridge []subreconciler.FnWithRequest
 
 	// We need to trigger a Synapse reconciliation so that it becomes aware of
-	// the Heisenbridge.
+	// the Heisenbridge. The image pull Secrets are checked first.
 	subreconcilersForHeisenbridge = []subreconciler.FnWithRequest{
+		r.checkImagePullSecrets,
 		r.triggerSynapseReconciliation,
 	}
 
@@ -98,6 +105,8 @@ func (r *HeisenbridgeReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		subreconcilersForHeisenbridge,
 		r.reconcileHeisenbridgeService,
 		r.reconcileHeisenbridgeDeployment,
+		r.updateHeisenbridgeStatusEndpoints,
+		r.checkSynapseConnectivity,
 	)
 
 	// Run all subreconcilers sequentially
@@ -155,6 +164,23 @@ func (r *HeisenbridgeReconciler) fetchSynapseInstance(
 	return r.Get(ctx, keyForSynapse, s)
 }
 
+// checkImagePullSecrets is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It verifies that the image pull Secrets listed in the Heisenbridge Spec
+// exist. If one is missing, the Heisenbridge State is set to FAILED and the
+// reconciliation is requeued, waiting for the Secret to be created.
+func (r *HeisenbridgeReconciler) checkImagePullSecrets(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	h := &synapsev1alpha1.Heisenbridge{}
+	if r, err := r.getLatestHeisenbridge(ctx, req, h); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	return utils.CheckImagePullSecrets(ctx, r.Client, h.Namespace, h.Spec.ImagePullSecrets, func(reason string) error {
+		return r.setFailedState(ctx, h, reason)
+	})
+}
+
 func (r *HeisenbridgeReconciler) triggerSynapseReconciliation(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
 	log := ctrllog.FromContext(ctx)
 
@@ -178,6 +204,31 @@ func (r *HeisenbridgeReconciler) triggerSynapseReconciliation(ctx context.Contex
 	return subreconciler.ContinueReconciling()
 }
 
+// updateHeisenbridgeStatusEndpoints is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It lists the in-cluster URLs of the endpoints served by the bridge in the
+// Heisenbridge Status.
+func (r *HeisenbridgeReconciler) updateHeisenbridgeStatusEndpoints(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	h := &synapsev1alpha1.Heisenbridge{}
+	if r, err := r.getLatestHeisenbridge(ctx, req, h); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	h.Status.Endpoints = map[string]string{
+		"appservice": "http://" + GetHeisenbridgeServiceFQDN(*h) + ":9898",
+	}
+
+	if err := r.updateHeisenbridgeStatus(ctx, h); err != nil {
+		log.Error(err, "Error updating Heisenbridge Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
 func (r *HeisenbridgeReconciler) setFailedState(ctx context.Context, h *synapsev1alpha1.Heisenbridge, reason string) error {
 	h.Status.State = "FAILED"
 	h.Status.Reason = reason
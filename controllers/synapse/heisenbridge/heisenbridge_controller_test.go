@@ -352,6 +352,9 @@ var _ = Describe("Integration tests for the Heisenbridge controller", Ordered, L
 					expectedStatus := synapsev1alpha1.HeisenbridgeStatus{
 						State:  "",
 						Reason: "",
+						Endpoints: map[string]string{
+							"appservice": "http://" + HeisenbridgeName + "." + HeisenbridgeNamespace + ".svc.cluster.local:9898",
+						},
 					}
 
 					// Status may need some time to be updated
@@ -437,6 +440,9 @@ var _ = Describe("Integration tests for the Heisenbridge controller", Ordered, L
 					expectedStatus := synapsev1alpha1.HeisenbridgeStatus{
 						State:  "",
 						Reason: "",
+						Endpoints: map[string]string{
+							"appservice": "http://" + HeisenbridgeName + "." + HeisenbridgeNamespace + ".svc.cluster.local:9898",
+						},
 					}
 					// Status may need some time to be updated
 					Eventually(func() synapsev1alpha1.HeisenbridgeStatus {
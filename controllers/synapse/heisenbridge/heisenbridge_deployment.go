@@ -18,6 +18,7 @@ package heisenbridge
 
 import (
 	"context"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -30,6 +31,9 @@ import (
 	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
+// Image of the Heisenbridge container
+const heisenbridgeImage = "hif1/heisenbridge:1.14"
+
 // labelsForSynapse returns the labels for selecting the resources
 // belonging to the given synapse CR name.
 func labelsForHeisenbridge(name string) map[string]string {
@@ -58,6 +62,7 @@ func (r *HeisenbridgeReconciler) reconcileHeisenbridgeDeployment(ctx context.Con
 		r.Client,
 		desiredDeployment,
 		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentStrategy(desiredDeployment),
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -87,12 +92,13 @@ func (r *HeisenbridgeReconciler) deploymentForHeisenbridge(h *synapsev1alpha1.He
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: h.Spec.ImagePullSecrets,
 					Containers: []corev1.Container{{
-						Image: "hif1/heisenbridge:1.14",
+						Image: heisenbridgeImage,
 						Name:  "heisenbridge",
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "data-heisenbridge",
-							MountPath: "/data-heisenbridge",
+							MountPath: utils.HeisenbridgeConfigDir,
 						}},
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 9898,
@@ -113,6 +119,23 @@ func (r *HeisenbridgeReconciler) deploymentForHeisenbridge(h *synapsev1alpha1.He
 			},
 		},
 	}
+
+	if isIdentdEnabled(*h) {
+		dep.Spec.Template.Spec.Containers[0].Ports = append(
+			dep.Spec.Template.Spec.Containers[0].Ports,
+			corev1.ContainerPort{
+				Name:          "identd",
+				ContainerPort: identdContainerPort,
+				HostPort:      identdHostPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		)
+		// The new pod can't bind the host port while the old one runs
+		dep.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, utils.MergeDefaultRequests(r.DefaultRequests, h.Spec.DefaultRequests))
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(h, dep, r.Scheme); err != nil {
 		return &appsv1.Deployment{}, err
@@ -138,10 +161,14 @@ func (r *HeisenbridgeReconciler) craftHeisenbridgeCommad(h synapsev1alpha1.Heise
 	SynapseName := h.Spec.Synapse.Name
 	SynapseNamespace := utils.ComputeNamespace(h.Namespace, h.Spec.Synapse.Namespace)
 
+	if isIdentdEnabled(h) {
+		command = append(command, "--identd", "--identd-port", strconv.Itoa(identdContainerPort))
+	}
+
 	command = append(
 		command,
 		"-c",
-		"/data-heisenbridge/heisenbridge.yaml",
+		utils.HeisenbridgeConfigPath,
 		"-l",
 		"0.0.0.0",
 		"http://"+utils.ComputeFQDN(SynapseName, SynapseNamespace)+":8008",
@@ -149,3 +176,16 @@ func (r *HeisenbridgeReconciler) craftHeisenbridgeCommad(h synapsev1alpha1.Heise
 
 	return command
 }
+
+// identdHostPort is the port of the node on which the IRC servers send their
+// ident queries. identdContainerPort is the unprivileged port on which the
+// identd service listens in the container.
+const (
+	identdHostPort      = 113
+	identdContainerPort = 1113
+)
+
+// isIdentdEnabled returns true if the identd service of the bridge is enabled
+func isIdentdEnabled(h synapsev1alpha1.Heisenbridge) bool {
+	return h.Spec.Identd != nil && h.Spec.Identd.Enabled
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heisenbridge
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// GetHeisenbridgeSelfTestResourceName returns the name of the Job checking
+// that the given Heisenbridge can reach Synapse
+func GetHeisenbridgeSelfTestResourceName(h synapsev1alpha1.Heisenbridge) string {
+	return h.Name + "-selftest"
+}
+
+// checkSynapseConnectivity is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// If Spec.SelfTest is enabled, it runs a Job querying the Synapse client API
+// from a Pod with the labels of the Heisenbridge Pods, and reports the result
+// in the Heisenbridge Status. Otherwise, the result of a previous check is
+// removed.
+func (r *HeisenbridgeReconciler) checkSynapseConnectivity(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	h := &synapsev1alpha1.Heisenbridge{}
+	if r, err := r.getLatestHeisenbridge(ctx, req, h); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if !h.Spec.SelfTest {
+		h.Status.SynapseConnectivity = nil
+		if err := r.updateHeisenbridgeStatus(ctx, h); err != nil {
+			log.Error(err, "Error updating Heisenbridge Status")
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.ContinueReconciling()
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetHeisenbridgeSelfTestResourceName(*h), h.Namespace, map[string]string{})
+	desiredJob, err := r.jobForSynapseConnectivity(h, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return utils.ReconcileSynapseConnectivity(
+		ctx,
+		r.Client,
+		desiredJob,
+		func(status *synapsev1alpha1.BridgeStatusSynapseConnectivity) error {
+			h.Status.SynapseConnectivity = status
+			return r.updateHeisenbridgeStatus(ctx, h)
+		},
+	)
+}
+
+// jobForSynapseConnectivity returns a Job checking that Synapse can be
+// reached on the URL used by the given Heisenbridge
+func (r *HeisenbridgeReconciler) jobForSynapseConnectivity(h *synapsev1alpha1.Heisenbridge, objectMeta metav1.ObjectMeta) (*batchv1.Job, error) {
+	job := utils.SynapseConnectivityJob(
+		objectMeta,
+		labelsForHeisenbridge(h.Name),
+		heisenbridgeImage,
+		h.Spec.ImagePullSecrets,
+		utils.SynapseClientVersionsURL(
+			h.Spec.Synapse.Name,
+			utils.ComputeNamespace(h.Namespace, h.Spec.Synapse.Namespace),
+		),
+	)
+
+	utils.SetDefaultRequests(&job.Spec.Template.Spec, utils.MergeDefaultRequests(r.DefaultRequests, h.Spec.DefaultRequests))
+
+	// Set Heisenbridge instance as the owner and controller
+	if err := ctrl.SetControllerReference(h, job, r.Scheme); err != nil {
+		return &batchv1.Job{}, err
+	}
+	return job, nil
+}
@@ -3,3 +3,191 @@
 //
 
 package heisenbridge
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("Unit tests for Heisenbridge package", Label("unit"), func() {
+	Context("When generating the default heisenbridge.yaml", func() {
+		var r HeisenbridgeReconciler
+		var h synapsev1alpha1.Heisenbridge
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r = HeisenbridgeReconciler{Scheme: scheme}
+
+			h = synapsev1alpha1.Heisenbridge{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-heisenbridge",
+					Namespace: "test-namespace",
+				},
+			}
+		})
+
+		It("Should register the bridge with the given tokens", func() {
+			cm, err := r.configMapForHeisenbridge(&h, h.ObjectMeta, "as-token", "hs-token")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			registration, err := utils.LoadYAMLFileFromConfigMapData(*cm, "heisenbridge.yaml")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(registration).Should(HaveKeyWithValue("url", "http://test-heisenbridge.test-namespace.svc.cluster.local:9898"))
+			Expect(registration).Should(HaveKeyWithValue("as_token", "as-token"))
+			Expect(registration).Should(HaveKeyWithValue("hs_token", "hs-token"))
+			Expect(registration).Should(HaveKeyWithValue("sender_localpart", "heisenbridge"))
+			Expect(cm.OwnerReferences).Should(HaveLen(1))
+		})
+	})
+
+	Context("When crafting the Heisenbridge command", func() {
+		var r HeisenbridgeReconciler
+		var h synapsev1alpha1.Heisenbridge
+
+		BeforeEach(func() {
+			r = HeisenbridgeReconciler{}
+			h = synapsev1alpha1.Heisenbridge{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-heisenbridge",
+					Namespace: "test-namespace",
+				},
+				Spec: synapsev1alpha1.HeisenbridgeSpec{
+					Synapse: synapsev1alpha1.HeisenbridgeSynapseSpec{Name: "test-synapse"},
+				},
+			}
+		})
+
+		It("Should read the registration file registered in Synapse", func() {
+			Expect(r.craftHeisenbridgeCommad(h)).Should(Equal([]string{
+				"python", "-m", "heisenbridge",
+				"-c", "/data-heisenbridge/heisenbridge.yaml",
+				"-l", "0.0.0.0",
+				"http://test-synapse.test-namespace.svc.cluster.local:8008",
+			}))
+		})
+
+		It("Should pass the identd options", func() {
+			h.Spec.VerboseLevel = 2
+			h.Spec.Identd = &synapsev1alpha1.HeisenbridgeIdentd{Enabled: true}
+			Expect(r.craftHeisenbridgeCommad(h)).Should(Equal([]string{
+				"python", "-m", "heisenbridge",
+				"-vv",
+				"--identd", "--identd-port", "1113",
+				"-c", "/data-heisenbridge/heisenbridge.yaml",
+				"-l", "0.0.0.0",
+				"http://test-synapse.test-namespace.svc.cluster.local:8008",
+			}))
+		})
+
+		It("Should not run identd when it is disabled", func() {
+			h.Spec.Identd = &synapsev1alpha1.HeisenbridgeIdentd{}
+			Expect(r.craftHeisenbridgeCommad(h)).ShouldNot(ContainElement("--identd"))
+		})
+	})
+
+	Context("When creating the Heisenbridge Deployment and Service", func() {
+		var r HeisenbridgeReconciler
+		var h synapsev1alpha1.Heisenbridge
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r = HeisenbridgeReconciler{Scheme: scheme}
+
+			h = synapsev1alpha1.Heisenbridge{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-heisenbridge",
+					Namespace: "test-namespace",
+				},
+				Spec: synapsev1alpha1.HeisenbridgeSpec{
+					Synapse: synapsev1alpha1.HeisenbridgeSynapseSpec{Name: "test-synapse"},
+					Identd:  &synapsev1alpha1.HeisenbridgeIdentd{Enabled: true},
+				},
+			}
+		})
+
+		It("Should publish the identd port on the node", func() {
+			dep, err := r.deploymentForHeisenbridge(&h, metav1.ObjectMeta{Name: h.Name, Namespace: h.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].Ports).Should(ContainElement(corev1.ContainerPort{
+				Name:          "identd",
+				ContainerPort: 1113,
+				HostPort:      113,
+				Protocol:      corev1.ProtocolTCP,
+			}))
+			Expect(dep.Spec.Strategy.Type).Should(Equal(appsv1.RecreateDeploymentStrategyType))
+
+			service, err := r.serviceForHeisenbridge(&h, metav1.ObjectMeta{Name: h.Name, Namespace: h.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(service.Spec.Ports).Should(Equal([]corev1.ServicePort{{
+				Name:       "heisenbridge",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       9898,
+				TargetPort: intstr.FromInt(9898),
+			}}))
+		})
+
+		It("Should override the operator default requests with the ones of the Spec", func() {
+			r.DefaultRequests = corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			}
+			h.Spec.DefaultRequests = corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			}
+
+			dep, err := r.deploymentForHeisenbridge(&h, metav1.ObjectMeta{Name: h.Name, Namespace: h.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources.Requests).Should(Equal(corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			}))
+		})
+
+		It("Should roll out the Deployment when identd is disabled", func() {
+			h.Spec.Identd = nil
+			dep, err := r.deploymentForHeisenbridge(&h, metav1.ObjectMeta{Name: h.Name, Namespace: h.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Strategy.Type).Should(BeEmpty())
+			Expect(dep.Spec.Template.Spec.Containers[0].Ports).Should(HaveLen(1))
+		})
+	})
+
+	Context("When checking that the bridge can reach Synapse", func() {
+		It("Should run the self-test Job with the labels and image of the Heisenbridge Pods", func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r := HeisenbridgeReconciler{Scheme: scheme}
+
+			h := synapsev1alpha1.Heisenbridge{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-heisenbridge", Namespace: "test-namespace"},
+				Spec: synapsev1alpha1.HeisenbridgeSpec{
+					Synapse:          synapsev1alpha1.HeisenbridgeSynapseSpec{Name: "test-synapse"},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-credentials"}},
+				},
+			}
+
+			job, err := r.jobForSynapseConnectivity(&h, metav1.ObjectMeta{Name: GetHeisenbridgeSelfTestResourceName(h), Namespace: h.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(job.Name).Should(Equal("test-heisenbridge-selftest"))
+			Expect(job.Namespace).Should(Equal("test-namespace"))
+			Expect(job.Spec.Template.Labels).Should(Equal(labelsForHeisenbridge(h.Name)))
+			Expect(job.Spec.Template.Spec.ImagePullSecrets).Should(Equal(h.Spec.ImagePullSecrets))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).Should(Equal(heisenbridgeImage))
+			Expect(job.Spec.Template.Spec.Containers[0].Args).Should(Equal([]string{
+				"http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions",
+			}))
+			Expect(metav1.IsControlledBy(job, &h)).Should(BeTrue())
+		})
+	})
+})
@@ -34,6 +34,10 @@ import (
 	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
+// Port of the mautrix-signal metrics listener, if enabled in config.yaml
+// without a listen_port
+const defaultMautrixSignalMetricsPort = 8000
+
 // reconcileMautrixSignalConfigMap is a function of type FnWithRequest, to
 // be called in the main reconciliation loop.
 //
@@ -170,9 +174,9 @@ signal:
     # make sure signald is configured to use an absolute path as the data directory.
     outgoing_attachment_dir: /tmp
     # Directory where signald stores avatars for groups.
-    avatar_dir: ~/.config/signald/avatars
+    avatar_dir: /signald/avatars
     # Directory where signald stores auth data. Used to delete data when logging out.
-    data_dir: ~/.config/signald/data
+    data_dir: /signald/data
     # Whether or not unknown signald accounts should be deleted when the bridge is started.
     # When this is enabled, any UserInUse errors should be resolved by restarting the bridge.
     delete_unknown_accounts_on_start: false
@@ -382,6 +386,11 @@ logging:
 		Data:       map[string]string{"config.yaml": configYaml},
 	}
 
+	// Apply the configuration options defined in the MautrixSignal Spec
+	if err := utils.UpdateConfigMapData(cm, ms, r.updateMautrixSignalData, "config.yaml", utils.MautrixSignalSchema); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(ms, cm, r.Scheme); err != nil {
 		return &corev1.ConfigMap{}, err
@@ -497,6 +506,11 @@ func (r *MautrixSignalReconciler) configureMautrixSignalConfigMap(ctx context.Co
 		return r, err
 	}
 
+	if ms.Spec.Homeserver != nil && ms.Spec.Homeserver.AsyncMedia {
+		log := ctrllog.FromContext(ctx)
+		log.Info("AsyncMedia is enabled. Media uploads will fail if the Synapse version in use doesn't support MSC2246.")
+	}
+
 	keyForConfigMap := types.NamespacedName{
 		Name:      ms.Name,
 		Namespace: ms.Namespace,
@@ -510,6 +524,7 @@ func (r *MautrixSignalReconciler) configureMautrixSignalConfigMap(ctx context.Co
 		ms,
 		r.updateMautrixSignalData,
 		"config.yaml",
+		utils.MautrixSignalSchema,
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -517,12 +532,40 @@ func (r *MautrixSignalReconciler) configureMautrixSignalConfigMap(ctx context.Co
 	return subreconciler.ContinueReconciling()
 }
 
+// getMautrixSignalConfig returns the config.yaml written in the
+// mautrix-signal ConfigMap
+func (r *MautrixSignalReconciler) getMautrixSignalConfig(ctx context.Context, ms *synapsev1alpha1.MautrixSignal) (map[string]interface{}, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ms.Name, Namespace: ms.Namespace}, cm); err != nil {
+		return nil, err
+	}
+
+	return utils.LoadYAMLFileFromConfigMapData(*cm, "config.yaml")
+}
+
+// mautrixSignalMetricsPort returns the port of the Prometheus metrics
+// listener configured in the given config.yaml, or 0 if metrics are
+// disabled.
+func mautrixSignalMetricsPort(config map[string]interface{}) int {
+	metrics, _ := config["metrics"].(map[interface{}]interface{})
+	if enabled, _ := metrics["enabled"].(bool); !enabled {
+		return 0
+	}
+
+	if port, ok := metrics["listen_port"].(int); ok {
+		return port
+	}
+	return defaultMautrixSignalMetricsPort
+}
+
 // updateMautrixSignalData is a function of type updateDataFunc function to
 // be passed as an argument in a call to updateConfigMap.
 //
-// It configures the user-provided config.yaml with the correct values. Among
-// other things, it ensures that the bridge can reach the Synapse homeserver
-// and knows the correct path to the signald socket.
+// It configures config.yaml with the correct values. Among other things, it
+// ensures that the bridge can reach the Synapse homeserver and knows the
+// correct path to the signald socket. It also applies the configuration
+// options defined in the MautrixSignal Spec. It is used both for the default
+// and the user-provided config.yaml.
 func (r *MautrixSignalReconciler) updateMautrixSignalData(
 	obj client.Object,
 	config map[string]interface{},
@@ -541,6 +584,24 @@ func (r *MautrixSignalReconciler) updateMautrixSignalData(
 	}
 	configHomeserver["address"] = "http://" + utils.ComputeFQDN(synapseName, synapseNamespace) + ":8008"
 	configHomeserver["domain"] = synapseServerName
+
+	// Update the connection options, if defined
+	if ms.Spec.Homeserver != nil {
+		if ms.Spec.Homeserver.ConnectionLimit > 0 {
+			configHomeserver["connection_limit"] = ms.Spec.Homeserver.ConnectionLimit
+		}
+		if ms.Spec.Homeserver.HTTPRetryCount > 0 {
+			configHomeserver["http_retry_count"] = ms.Spec.Homeserver.HTTPRetryCount
+		}
+		if ms.Spec.Homeserver.AsyncMedia {
+			configHomeserver["async_media"] = true
+		}
+		if ms.Spec.Homeserver.Software != "" {
+			configHomeserver["software"] = ms.Spec.Homeserver.Software
+			// Older bridge versions only understand the asmux flag
+			configHomeserver["asmux"] = ms.Spec.Homeserver.Software == "asmux"
+		}
+	}
 	config["homeserver"] = configHomeserver
 
 	// Update the appservice section so that Synapse can reach the bridge
@@ -550,15 +611,25 @@ func (r *MautrixSignalReconciler) updateMautrixSignalData(
 		return err
 	}
 	configAppservice["address"] = "http://" + utils.ComputeFQDN(ms.Name, ms.Namespace) + ":29328"
+	if ms.Spec.EphemeralEvents {
+		configAppservice["ephemeral_events"] = true
+	}
 	config["appservice"] = configAppservice
 
-	// Update the path to the signal socket path
+	// Update the path to the signal socket and to the signald data. The
+	// signald PVC is mounted on /signald in both the signald and the bridge
+	// containers. Using absolute paths on this PVC ensures that the bridge
+	// looks for the accounts where signald stores them, and that the auth
+	// data survives restarts (e.g. if delete_unknown_accounts_on_start is
+	// set, accounts not found in data_dir are deleted).
 	configSignal, ok := config["signal"].(map[interface{}]interface{})
 	if !ok {
 		err := errors.New("cannot parse mautrix-signal config.yaml: error parsing 'signal' section")
 		return err
 	}
 	configSignal["socket_path"] = "/signald/signald.sock"
+	configSignal["data_dir"] = "/signald/data"
+	configSignal["avatar_dir"] = "/signald/avatars"
 	config["signal"] = configSignal
 
 	// Update persmissions to use the correct domain name
@@ -572,6 +643,85 @@ func (r *MautrixSignalReconciler) updateMautrixSignalData(
 		synapseServerName:             "user",
 		"@admin:" + synapseServerName: "admin",
 	}
+
+	// Ephemeral events received via appservice transactions replace the
+	// ones obtained with /sync for double puppets
+	if ms.Spec.EphemeralEvents {
+		configBridge["sync_with_custom_puppets"] = false
+	}
+
+	// Update the encryption options, if defined
+	if ms.Spec.Encryption != nil {
+		configBridgeEncryption, ok := configBridge["encryption"].(map[interface{}]interface{})
+		if !ok {
+			configBridgeEncryption = map[interface{}]interface{}{}
+		}
+		configBridgeEncryption["allow"] = ms.Spec.Encryption.Allow
+
+		if ms.Spec.Encryption.KeySharing != nil {
+			configBridgeEncryptionKeySharing, ok := configBridgeEncryption["key_sharing"].(map[interface{}]interface{})
+			if !ok {
+				configBridgeEncryptionKeySharing = map[interface{}]interface{}{}
+			}
+			configBridgeEncryptionKeySharing["allow"] = ms.Spec.Encryption.KeySharing.Allow
+			configBridgeEncryptionKeySharing["require_cross_signing"] = ms.Spec.Encryption.KeySharing.RequireCrossSigning
+			configBridgeEncryption["key_sharing"] = configBridgeEncryptionKeySharing
+		}
+		configBridge["encryption"] = configBridgeEncryption
+	}
+
+	// Update the management room messages, if defined
+	if ms.Spec.ManagementRoomText != nil {
+		configBridgeManagementRoomText, ok := configBridge["management_room_text"].(map[interface{}]interface{})
+		if !ok {
+			configBridgeManagementRoomText = map[interface{}]interface{}{}
+		}
+		for key, text := range map[string]string{
+			"welcome":             ms.Spec.ManagementRoomText.Welcome,
+			"welcome_connected":   ms.Spec.ManagementRoomText.WelcomeConnected,
+			"welcome_unconnected": ms.Spec.ManagementRoomText.WelcomeUnconnected,
+			"additional_help":     ms.Spec.ManagementRoomText.AdditionalHelp,
+		} {
+			if text != "" {
+				configBridgeManagementRoomText[key] = text
+			}
+		}
+		configBridge["management_room_text"] = configBridgeManagementRoomText
+	}
+
+	// Update the command prefix, if defined
+	if ms.Spec.CommandPrefix != "" {
+		configBridge["command_prefix"] = ms.Spec.CommandPrefix
+	}
+
+	// Enable the delivery diagnostics, if requested
+	if ms.Spec.DeliveryReceipts {
+		configBridge["delivery_receipts"] = true
+	}
+	if ms.Spec.MessageStatusEvents {
+		configBridge["message_status_events"] = true
+	}
+
+	// Update the relay mode options, if defined
+	if ms.Spec.Relay != nil {
+		configBridgeRelay, ok := configBridge["relay"].(map[interface{}]interface{})
+		if !ok {
+			configBridgeRelay = map[interface{}]interface{}{}
+		}
+		configBridgeRelay["enabled"] = ms.Spec.Relay.Enabled
+
+		if len(ms.Spec.Relay.MessageFormats) > 0 {
+			configBridgeRelayMessageFormats, ok := configBridgeRelay["message_formats"].(map[interface{}]interface{})
+			if !ok {
+				configBridgeRelayMessageFormats = map[interface{}]interface{}{}
+			}
+			for msgType, format := range ms.Spec.Relay.MessageFormats {
+				configBridgeRelayMessageFormats[msgType] = format
+			}
+			configBridgeRelay["message_formats"] = configBridgeRelayMessageFormats
+		}
+		configBridge["relay"] = configBridgeRelay
+	}
 	config["bridge"] = configBridge
 
 	// Update the path to the log file
@@ -18,15 +18,24 @@ package mautrixsignal
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
@@ -37,6 +46,10 @@ import (
 type MautrixSignalReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Resource requests set on the containers without requests nor limits,
+	// unless overridden by Spec.DefaultRequests
+	DefaultRequests corev1.ResourceList
 }
 
 func GetSignaldResourceName(ms synapsev1alpha1.MautrixSignal) string {
@@ -50,6 +63,7 @@ func GetMautrixSignalServiceFQDN(ms synapsev1alpha1.MautrixSignal) string {
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=mautrixsignals,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=mautrixsignals/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=synapse.opdev.io,resources=mautrixsignals/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,9 +85,13 @@ func (r *MautrixSignalReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// We need to trigger a Synapse reconciliation so that it becomes aware of
 	// the MautrixSignal. We also need to complete the MautrixSignal Status.
+	// The MautrixSignal Spec is validated first.
 	subreconcilersForMautrixSignal = []subreconciler.FnWithRequest{
+		r.validateMautrixSignalSpec,
+		r.checkImagePullSecrets,
 		r.triggerSynapseReconciliation,
 		r.buildMautrixSignalStatus,
+		r.waitForSynapseRunning,
 	}
 
 	// The user may specify a ConfigMap, containing the config.yaml config
@@ -116,18 +134,46 @@ func (r *MautrixSignalReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		r.reconcileMautrixSignalService,
 		r.reconcileMautrixSignalPVC,
 		r.reconcileMautrixSignalDeployment,
+		r.updateMautrixSignalStatusEndpoints,
+		r.checkSynapseConnectivity,
 	)
 
 	// Run all subreconcilers sequentially
 	for _, f := range subreconcilersForMautrixSignal {
-		if r, err := f(ctx, req); subreconciler.ShouldHaltOrRequeue(r, err) {
-			return subreconciler.Evaluate(r, err)
+		if res, err := f(ctx, req); subreconciler.ShouldHaltOrRequeue(res, err) {
+			var schemaErr *utils.ConfigSchemaError
+			if errors.As(err, &schemaErr) {
+				return subreconciler.Evaluate(r.failOnInvalidConfig(ctx, req, err))
+			}
+			return subreconciler.Evaluate(res, err)
 		}
 	}
 
 	return subreconciler.Evaluate(subreconciler.DoNotRequeue())
 }
 
+// failOnInvalidConfig sets the MautrixSignal State to FAILED when the
+// config.yaml doesn't match its schema. Retrying won't help until the
+// MautrixSignal Spec or the input ConfigMap is modified.
+func (r *MautrixSignalReconciler) failOnInvalidConfig(ctx context.Context, req ctrl.Request, err error) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	ms.Status.State = "FAILED"
+	ms.Status.Reason = err.Error()
+
+	if err, _ := r.updateMautrixSignalStatus(ctx, ms); err != nil {
+		log.Error(err, "Error updating mautrix-signal State")
+	}
+
+	log.Error(err, "Invalid mautrix-signal configuration")
+	return subreconciler.DoNotRequeue()
+}
+
 func (r *MautrixSignalReconciler) getLatestMautrixSignal(
 	ctx context.Context,
 	req ctrl.Request,
@@ -173,6 +219,98 @@ func (r *MautrixSignalReconciler) fetchSynapseInstance(
 	return r.Get(ctx, keyForSynapse, s)
 }
 
+// validateMautrixSignalSpec is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It runs the validation checks on the MautrixSignal Spec which cannot be
+// expressed in the CRD schema. If the Spec is invalid, the MautrixSignal
+// State is set to FAILED and the reconciliation stops.
+func (r *MautrixSignalReconciler) validateMautrixSignalSpec(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if err := validateMautrixSignalSpec(ms.Spec, ms.Namespace); err != nil {
+		ms.Status.State = "FAILED"
+		ms.Status.Reason = err.Error()
+
+		if err, _ := r.updateMautrixSignalStatus(ctx, ms); err != nil {
+			log.Error(err, "Error updating mautrix-signal State")
+		}
+
+		log.Error(err, "Invalid mautrix-signal Spec")
+		return subreconciler.DoNotRequeue()
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// checkImagePullSecrets is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It verifies that the image pull Secrets listed in the MautrixSignal Spec
+// exist. If one is missing, the MautrixSignal State is set to FAILED and the
+// reconciliation is requeued, waiting for the Secret to be created.
+func (r *MautrixSignalReconciler) checkImagePullSecrets(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	return utils.CheckImagePullSecrets(ctx, r.Client, ms.Namespace, ms.Spec.ImagePullSecrets, func(reason string) error {
+		ms.Status.State = "FAILED"
+		ms.Status.Reason = reason
+
+		err, _ := r.updateMautrixSignalStatus(ctx, ms)
+		return err
+	})
+}
+
+// validateMautrixSignalSpec returns an error describing the first invalid
+// option found in the Spec of a MautrixSignal living in the given namespace,
+// if any.
+func validateMautrixSignalSpec(spec synapsev1alpha1.MautrixSignalSpec, namespace string) error {
+	// Synapse mounts the bridge PVC to read the registration.yaml file. As a
+	// Pod can only mount PVCs from its own namespace, the bridge and the
+	// Synapse instance must live in the same namespace.
+	if utils.ComputeNamespace(namespace, spec.Synapse.Namespace) != namespace {
+		return errors.New(
+			"the Synapse instance must be in the same namespace as the MautrixSignal bridge (" +
+				namespace + "), cross-namespace references are not supported",
+		)
+	}
+
+	if spec.Encryption != nil && !spec.Encryption.Allow &&
+		spec.Encryption.KeySharing != nil && spec.Encryption.KeySharing.Allow {
+		return errors.New("encryption key sharing cannot be enabled if encryption is not allowed")
+	}
+
+	if spec.Homeserver != nil {
+		switch spec.Homeserver.Software {
+		case "", "standard", "asmux", "hungry":
+		default:
+			return errors.New("unknown homeserver software: " + spec.Homeserver.Software)
+		}
+	}
+
+	// The drain period is part of the termination grace period: the
+	// containers would be killed before disconnecting if it is not shorter
+	if spec.Shutdown != nil {
+		gracePeriod := int64(30)
+		if spec.Shutdown.TerminationGracePeriodSeconds != nil {
+			gracePeriod = *spec.Shutdown.TerminationGracePeriodSeconds
+		}
+		if spec.Shutdown.DrainSeconds > 0 && spec.Shutdown.DrainSeconds >= gracePeriod {
+			return errors.New("the shutdown drain period must be shorter than the termination grace period")
+		}
+	}
+
+	return nil
+}
+
 func (r *MautrixSignalReconciler) triggerSynapseReconciliation(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
 	log := ctrllog.FromContext(ctx)
 
@@ -238,6 +376,104 @@ func (r *MautrixSignalReconciler) buildMautrixSignalStatus(ctx context.Context,
 	return subreconciler.ContinueReconciling()
 }
 
+// waitForSynapseRunning is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It ensures that the Synapse instance referenced by the bridge is RUNNING
+// before the bridge resources are created. Until then, the MautrixSignal
+// State is set to WaitingForSynapse and the reconciliation is requeued.
+func (r *MautrixSignalReconciler) waitForSynapseRunning(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	s := synapsev1alpha1.Synapse{}
+	if err := r.fetchSynapseInstance(ctx, *ms, &s); err != nil {
+		log.Error(err, "Error fetching Synapse instance")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if s.Status.State != "RUNNING" {
+		ms.Status.State = "WaitingForSynapse"
+		ms.Status.Reason = "Synapse instance " + s.Name + " is not RUNNING yet"
+		if err, _ := r.updateMautrixSignalStatus(ctx, ms); err != nil {
+			log.Error(err, "Error updating mautrix-signal State")
+		}
+
+		log.Info("Waiting for Synapse to be RUNNING", "Synapse Name", s.Name, "Synapse State", s.Status.State)
+		return subreconciler.RequeueWithDelay(10 * time.Second)
+	}
+
+	if ms.Status.State == "WaitingForSynapse" {
+		ms.Status.State = ""
+		ms.Status.Reason = ""
+		err, has_patched := r.updateMautrixSignalStatus(ctx, ms)
+		if err != nil {
+			log.Error(err, "Error updating mautrix-signal State")
+			return subreconciler.RequeueWithError(err)
+		}
+		if has_patched {
+			return subreconciler.Requeue()
+		}
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateMautrixSignalStatusEndpoints is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It lists the in-cluster URLs of the endpoints served by the bridge in the
+// MautrixSignal Status, based on the config.yaml written in the
+// mautrix-signal ConfigMap.
+func (r *MautrixSignalReconciler) updateMautrixSignalStatusEndpoints(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	config, err := r.getMautrixSignalConfig(ctx, ms)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	ms.Status.Endpoints = endpointsForMautrixSignal(ms, config)
+
+	if err, _ := r.updateMautrixSignalStatus(ctx, ms); err != nil {
+		log.Error(err, "Error updating mautrix-signal Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// endpointsForMautrixSignal returns the in-cluster URLs of the endpoints
+// served by the bridge, given its config.yaml. The provisioning API is served
+// by the appservice web server, under the configured prefix. The metrics are
+// served on their own port, exposed by the mautrix-signal Service.
+func endpointsForMautrixSignal(ms *synapsev1alpha1.MautrixSignal, config map[string]interface{}) map[string]string {
+	appservice := "http://" + GetMautrixSignalServiceFQDN(*ms) + ":29328"
+	endpoints := map[string]string{"appservice": appservice}
+
+	if metricsPort := mautrixSignalMetricsPort(config); metricsPort != 0 {
+		endpoints["metrics"] = "http://" + GetMautrixSignalServiceFQDN(*ms) + ":" + strconv.Itoa(metricsPort) + "/metrics"
+	}
+
+	bridge, _ := config["bridge"].(map[interface{}]interface{})
+	provisioning, _ := bridge["provisioning"].(map[interface{}]interface{})
+	if enabled, _ := provisioning["enabled"].(bool); enabled {
+		prefix, _ := provisioning["prefix"].(string)
+		endpoints["provisioning"] = appservice + prefix
+	}
+
+	return endpoints
+}
+
 func (r *MautrixSignalReconciler) updateMautrixSignalStatus(ctx context.Context, ms *synapsev1alpha1.MautrixSignal) (error, bool) {
 	current := &synapsev1alpha1.MautrixSignal{}
 	if err := r.Get(
@@ -262,5 +498,57 @@ func (r *MautrixSignalReconciler) updateMautrixSignalStatus(ctx context.Context,
 func (r *MautrixSignalReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&synapsev1alpha1.MautrixSignal{}).
+		Watches(
+			&source.Kind{Type: &synapsev1alpha1.Synapse{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSynapseBridges),
+			builder.WithPredicates(synapseConfigurationChanged()),
+		).
 		Complete(r)
 }
+
+// requestsForSynapseBridges maps a Synapse object to reconciliation requests
+// for all MautrixSignal bridges referencing it, so that the bridges
+// recompute their configuration when the Synapse configuration changes.
+func (r *MautrixSignalReconciler) requestsForSynapseBridges(obj client.Object) []ctrl.Request {
+	msList := &synapsev1alpha1.MautrixSignalList{}
+	if err := r.List(context.Background(), msList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	requests := []ctrl.Request{}
+	for _, ms := range msList.Items {
+		if ms.Spec.Synapse.Name == obj.GetName() &&
+			utils.ComputeNamespace(ms.Namespace, ms.Spec.Synapse.Namespace) == obj.GetNamespace() {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: ms.Name, Namespace: ms.Namespace},
+			})
+		}
+	}
+
+	return requests
+}
+
+// synapseConfigurationChanged returns a predicate filtering the Synapse
+// updates relevant to the bridges: a change of the Spec, of the server name
+// parsed from homeserver.yaml, or of the Synapse State. Other Status updates,
+// which happen at each Synapse reconciliation, are ignored.
+func synapseConfigurationChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		DeleteFunc: func(event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSynapse, ok := e.ObjectOld.(*synapsev1alpha1.Synapse)
+			if !ok {
+				return false
+			}
+			newSynapse, ok := e.ObjectNew.(*synapsev1alpha1.Synapse)
+			if !ok {
+				return false
+			}
+
+			return oldSynapse.Generation != newSynapse.Generation ||
+				oldSynapse.Status.HomeserverConfiguration.ServerName != newSynapse.Status.HomeserverConfiguration.ServerName ||
+				oldSynapse.Status.State != newSynapse.Status.State
+		},
+	}
+}
@@ -169,6 +169,24 @@ var _ = Describe("Integration tests for the MautrixSignal controller", Ordered,
 						},
 					},
 				}),
+				Entry("when MautrixSignal spec command prefix starts with an alphanumeric character", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"synapse": map[string]interface{}{
+							"name": "dummy",
+						},
+						"commandPrefix": "signal",
+					},
+				}),
+				Entry("when MautrixSignal spec homeserver connection limit is not positive", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"synapse": map[string]interface{}{
+							"name": "dummy",
+						},
+						"homeserver": map[string]interface{}{
+							"connectionLimit": 0,
+						},
+					},
+				}),
 				// This should not work but passes
 				PEntry("when MautrixSignal spec possesses an invalid field", map[string]interface{}{
 					"spec": map[string]interface{}{
@@ -303,6 +321,7 @@ var _ = Describe("Integration tests for the MautrixSignal controller", Ordered,
 						ServerName:  SynapseServerName,
 						ReportStats: false,
 					},
+					State: "RUNNING",
 				}
 				Expect(k8sClient.Status().Update(ctx, synapse)).Should(Succeed())
 
@@ -397,6 +416,10 @@ var _ = Describe("Integration tests for the MautrixSignal controller", Ordered,
 							ServerName: SynapseServerName,
 						},
 						IsOpenshift: true,
+						Endpoints: map[string]string{
+							"appservice":   "http://" + MautrixSignalName + "." + MautrixSignalNamespace + ".svc.cluster.local:29328",
+							"provisioning": "http://" + MautrixSignalName + "." + MautrixSignalNamespace + ".svc.cluster.local:29328" + "/_matrix/provision",
+						},
 					}
 
 					// Status may need some time to be updated
@@ -523,6 +546,9 @@ logging:
 							ServerName: SynapseServerName,
 						},
 						IsOpenshift: true,
+						Endpoints: map[string]string{
+							"appservice": "http://" + MautrixSignalName + "." + MautrixSignalNamespace + ".svc.cluster.local:29328",
+						},
 					}
 					// Status may need some time to be updated
 					Eventually(func() synapsev1alpha1.MautrixSignalStatus {
@@ -628,6 +654,55 @@ logging:
 				})
 			})
 
+			When("The referenced Synapse is not RUNNING yet", func() {
+				BeforeAll(func() {
+					initMautrixSignalVariables()
+
+					mautrixsignalSpec = synapsev1alpha1.MautrixSignalSpec{
+						Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{
+							Name:      SynapseName,
+							Namespace: SynapseNamespace,
+						},
+					}
+
+					createSynapseInstanceForMautrixSignal()
+					synapse.Status.State = ""
+					Expect(k8sClient.Status().Update(ctx, synapse)).Should(Succeed())
+
+					createMautrixSignalInstance()
+				})
+
+				AfterAll(func() {
+					cleanupMautrixSignalResources()
+					cleanupSynapseCR()
+				})
+
+				It("Should wait for Synapse before creating the MautrixSignal resources", func() {
+					checkStatus(
+						"WaitingForSynapse",
+						"Synapse instance "+SynapseName+" is not RUNNING yet",
+						mautrixsignalLookupKey,
+						mautrixsignal,
+					)
+					checkSubresourceAbsence(
+						mautrixsignalLookupKey,
+						createdConfigMap,
+						createdPVC,
+						createdDeployment,
+						createdService,
+					)
+				})
+
+				It("Should create the MautrixSignal resources once Synapse is RUNNING", func() {
+					Expect(k8sClient.Get(ctx, synapseLookupKey, synapse)).Should(Succeed())
+					synapse.Status.State = "RUNNING"
+					Expect(k8sClient.Status().Update(ctx, synapse)).Should(Succeed())
+
+					checkStatus("", "", mautrixsignalLookupKey, mautrixsignal)
+					checkResourcePresence(createdDeployment, mautrixsignalLookupKey, expectedOwnerReference)
+				})
+			})
+
 			When("MautrixSignal references a non existing Synapse", func() {
 				BeforeAll(func() {
 					initMautrixSignalVariables()
@@ -18,6 +18,8 @@ package mautrixsignal
 
 import (
 	"context"
+	"path"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -27,8 +29,12 @@ import (
 	"github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
 	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
+// Image of the mautrix-signal container
+const mautrixSignalImage = "dock.mau.dev/mautrix/signal:v0.4.1"
+
 // labelsForMautrixSignal returns the labels for selecting the resources
 // belonging to the given synapse CR name.
 func labelsForMautrixSignal(name string) map[string]string {
@@ -57,6 +63,7 @@ func (r *MautrixSignalReconciler) reconcileMautrixSignalDeployment(ctx context.C
 		r.Client,
 		desiredDeployment,
 		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentScheduling(desiredDeployment),
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -64,6 +71,48 @@ func (r *MautrixSignalReconciler) reconcileMautrixSignalDeployment(ctx context.C
 	return subreconciler.ContinueReconciling()
 }
 
+// setContainerWorkingDir sets the working directory of the given bridge
+// container, whose data volume is mounted on mountPath. Unless disabled in
+// the MautrixSignal Spec, HOME is also set to the working directory.
+func setContainerWorkingDir(ms *synapsev1alpha1.MautrixSignal, container *corev1.Container, mountPath string) {
+	container.WorkingDir = path.Join(mountPath, ms.Spec.WorkingDir)
+
+	if ms.Spec.SetHome == nil || *ms.Spec.SetHome {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "HOME", Value: container.WorkingDir})
+	}
+}
+
+// setShutdownDrain configures the graceful shutdown of the given bridge Pod,
+// as defined in the MautrixSignal Spec. A preStop hook delays the SIGTERM
+// sent to each container by the configured drain period.
+func setShutdownDrain(ms *synapsev1alpha1.MautrixSignal, podSpec *corev1.PodSpec) {
+	if ms.Spec.Shutdown == nil {
+		return
+	}
+
+	podSpec.TerminationGracePeriodSeconds = ms.Spec.Shutdown.TerminationGracePeriodSeconds
+
+	if ms.Spec.Shutdown.DrainSeconds > 0 {
+		for i := range podSpec.Containers {
+			podSpec.Containers[i].Lifecycle = &corev1.Lifecycle{
+				PreStop: &corev1.LifecycleHandler{
+					Exec: &corev1.ExecAction{
+						Command: []string{"/bin/sh", "-c", "sleep " + strconv.FormatInt(ms.Spec.Shutdown.DrainSeconds, 10)},
+					},
+				},
+			}
+		}
+	}
+}
+
+// setScheduling applies the scheduling constraints of the MautrixSignal Spec
+// to the given bridge Pod. Unset constraints are left empty.
+func setScheduling(ms *synapsev1alpha1.MautrixSignal, podSpec *corev1.PodSpec) {
+	podSpec.NodeSelector = ms.Spec.NodeSelector
+	podSpec.Tolerations = ms.Spec.Tolerations
+	podSpec.Affinity = ms.Spec.Affinity
+}
+
 // deploymentForMautrixSignal returns a Deployment object for the mautrix-signal bridge
 func (r *MautrixSignalReconciler) deploymentForMautrixSignal(ms *synapsev1alpha1.MautrixSignal, objectMeta metav1.ObjectMeta) (*appsv1.Deployment, error) {
 	ls := labelsForMautrixSignal(ms.Name)
@@ -90,6 +139,7 @@ func (r *MautrixSignalReconciler) deploymentForMautrixSignal(ms *synapsev1alpha1
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: ms.Spec.ImagePullSecrets,
 					// The init container is responsible of copying the
 					// config.yaml from the read-only ConfigMap to the
 					// mautrixsignal-data volume. The mautrixsignal process
@@ -108,7 +158,7 @@ func (r *MautrixSignalReconciler) deploymentForMautrixSignal(ms *synapsev1alpha1
 						Args:    []string{"if [ ! -f /data/config.yaml ]; then cp /input/config.yaml /data/config.yaml; fi"},
 					}},
 					Containers: []corev1.Container{{
-						Image: "dock.mau.dev/mautrix/signal:v0.4.1",
+						Image: mautrixSignalImage,
 						Name:  "mautrix-signal",
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "signald",
@@ -147,6 +197,10 @@ func (r *MautrixSignalReconciler) deploymentForMautrixSignal(ms *synapsev1alpha1
 		},
 	}
 
+	setContainerWorkingDir(ms, &dep.Spec.Template.Spec.Containers[0], "/data")
+	setShutdownDrain(ms, &dep.Spec.Template.Spec)
+	setScheduling(ms, &dep.Spec.Template.Spec)
+
 	if ms.Status.IsOpenshift {
 		// mautrix-signal must run with user 1337.
 		// If deploying on Openshift, we must run the workload with a Service
@@ -154,6 +208,8 @@ func (r *MautrixSignalReconciler) deploymentForMautrixSignal(ms *synapsev1alpha1
 		dep.Spec.Template.Spec.ServiceAccountName = mautrixSignalServiceAccountName
 	}
 
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, utils.MergeDefaultRequests(r.DefaultRequests, ms.Spec.DefaultRequests))
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(ms, dep, r.Scheme); err != nil {
 		return &appsv1.Deployment{}, err
@@ -46,12 +46,7 @@ func (r *MautrixSignalReconciler) reconcileMautrixSignalPVC(ctx context.Context,
 		return subreconciler.RequeueWithError(err)
 	}
 
-	if err := reconcile.ReconcileResource(
-		ctx,
-		r.Client,
-		desiredPVC,
-		&corev1.PersistentVolumeClaim{},
-	); err != nil {
+	if err := reconcile.ReconcilePersistentVolumeClaim(ctx, r.Client, desiredPVC); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
 
@@ -72,6 +67,7 @@ func (r *MautrixSignalReconciler) persistentVolumeClaimForMautrixSignal(ms *syna
 					"storage": *resource.NewQuantity(5*1024*1024*1024, resource.BinarySI),
 				},
 			},
+			StorageClassName: storageClassNameForMautrixSignal(ms),
 		},
 	}
 
@@ -81,3 +77,13 @@ func (r *MautrixSignalReconciler) persistentVolumeClaimForMautrixSignal(ms *syna
 	}
 	return pvc, nil
 }
+
+// storageClassNameForMautrixSignal returns the storage class requested for
+// the mautrix-signal and signald PVCs. nil is returned if none is requested,
+// so that the cluster default applies.
+func storageClassNameForMautrixSignal(ms *synapsev1alpha1.MautrixSignal) *string {
+	if ms.Spec.Storage == nil {
+		return nil
+	}
+	return ms.Spec.Storage.StorageClassName
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mautrixsignal
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// GetMautrixSignalSelfTestResourceName returns the name of the Job checking
+// that the given mautrix-signal bridge can reach Synapse
+func GetMautrixSignalSelfTestResourceName(ms synapsev1alpha1.MautrixSignal) string {
+	return ms.Name + "-selftest"
+}
+
+// checkSynapseConnectivity is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// If Spec.SelfTest is enabled, it runs a Job querying the Synapse client API
+// from a Pod with the labels of the mautrix-signal Pods, and reports the
+// result in the MautrixSignal Status. Otherwise, the result of a previous
+// check is removed.
+func (r *MautrixSignalReconciler) checkSynapseConnectivity(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	ms := &synapsev1alpha1.MautrixSignal{}
+	if r, err := r.getLatestMautrixSignal(ctx, req, ms); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if !ms.Spec.SelfTest {
+		ms.Status.SynapseConnectivity = nil
+		if err, _ := r.updateMautrixSignalStatus(ctx, ms); err != nil {
+			log.Error(err, "Error updating mautrix-signal Status")
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.ContinueReconciling()
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetMautrixSignalSelfTestResourceName(*ms), ms.Namespace, map[string]string{})
+	desiredJob, err := r.jobForSynapseConnectivity(ms, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return utils.ReconcileSynapseConnectivity(
+		ctx,
+		r.Client,
+		desiredJob,
+		func(status *synapsev1alpha1.BridgeStatusSynapseConnectivity) error {
+			ms.Status.SynapseConnectivity = status
+			err, _ := r.updateMautrixSignalStatus(ctx, ms)
+			return err
+		},
+	)
+}
+
+// jobForSynapseConnectivity returns a Job checking that Synapse can be
+// reached on the URL used by the given mautrix-signal bridge
+func (r *MautrixSignalReconciler) jobForSynapseConnectivity(ms *synapsev1alpha1.MautrixSignal, objectMeta metav1.ObjectMeta) (*batchv1.Job, error) {
+	job := utils.SynapseConnectivityJob(
+		objectMeta,
+		labelsForMautrixSignal(ms.Name),
+		mautrixSignalImage,
+		ms.Spec.ImagePullSecrets,
+		utils.SynapseClientVersionsURL(
+			ms.Spec.Synapse.Name,
+			utils.ComputeNamespace(ms.Namespace, ms.Spec.Synapse.Namespace),
+		),
+	)
+
+	utils.SetDefaultRequests(&job.Spec.Template.Spec, utils.MergeDefaultRequests(r.DefaultRequests, ms.Spec.DefaultRequests))
+
+	// Set MautrixSignal instance as the owner and controller
+	if err := ctrl.SetControllerReference(ms, job, r.Scheme); err != nil {
+		return &batchv1.Job{}, err
+	}
+	return job, nil
+}
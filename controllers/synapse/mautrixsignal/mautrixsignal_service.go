@@ -39,9 +39,14 @@ func (r *MautrixSignalReconciler) reconcileMautrixSignalService(ctx context.Cont
 		return r, err
 	}
 
+	config, err := r.getMautrixSignalConfig(ctx, ms)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
 	objectMetaMautrixSignal := reconcile.SetObjectMeta(ms.Name, ms.Namespace, map[string]string{})
 
-	desiredService, err := r.serviceForMautrixSignal(ms, objectMetaMautrixSignal)
+	desiredService, err := r.serviceForMautrixSignal(ms, objectMetaMautrixSignal, mautrixSignalMetricsPort(config))
 	if err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -58,8 +63,9 @@ func (r *MautrixSignalReconciler) reconcileMautrixSignalService(ctx context.Cont
 	return subreconciler.ContinueReconciling()
 }
 
-// serviceForMautrixSignal returns a mautrix-signal Service object
-func (r *MautrixSignalReconciler) serviceForMautrixSignal(ms *synapsev1alpha1.MautrixSignal, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
+// serviceForMautrixSignal returns a mautrix-signal Service object. The
+// metrics port is only exposed if it isn't 0.
+func (r *MautrixSignalReconciler) serviceForMautrixSignal(ms *synapsev1alpha1.MautrixSignal, objectMeta metav1.ObjectMeta, metricsPort int) (*corev1.Service, error) {
 	service := &corev1.Service{
 		ObjectMeta: objectMeta,
 		Spec: corev1.ServiceSpec{
@@ -73,6 +79,14 @@ func (r *MautrixSignalReconciler) serviceForMautrixSignal(ms *synapsev1alpha1.Ma
 			Type:     corev1.ServiceTypeClusterIP,
 		},
 	}
+	if metricsPort != 0 {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       "metrics",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       int32(metricsPort),
+			TargetPort: intstr.FromInt(metricsPort),
+		})
+	}
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(ms, service, r.Scheme); err != nil {
 		return &corev1.Service{}, err
@@ -3,3 +3,554 @@
 //
 
 package mautrixsignal
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Unit tests for MautrixSignal package", Label("unit"), func() {
+	Context("When generating the default config.yaml", func() {
+		var r MautrixSignalReconciler
+		var ms synapsev1alpha1.MautrixSignal
+		var config map[string]interface{}
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r = MautrixSignalReconciler{Scheme: scheme}
+
+			ms = synapsev1alpha1.MautrixSignal{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-mautrixsignal",
+					Namespace: "test-namespace",
+				},
+				Spec: synapsev1alpha1.MautrixSignalSpec{
+					Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{Name: "test-synapse"},
+				},
+				Status: synapsev1alpha1.MautrixSignalStatus{
+					Synapse: synapsev1alpha1.MautrixSignalStatusSynapse{ServerName: "example.com"},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			cm, err := r.configMapForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			config, err = utils.LoadYAMLFileFromConfigMapData(*cm, "config.yaml")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should configure the homeserver section", func() {
+			Expect(config["homeserver"]).Should(HaveKeyWithValue("domain", "example.com"))
+			Expect(config["homeserver"]).Should(HaveKeyWithValue(
+				"address",
+				"http://test-synapse.test-namespace.svc.cluster.local:8008",
+			))
+		})
+
+		When("when encryption and key sharing are enabled", func() {
+			BeforeEach(func() {
+				ms.Spec.Encryption = &synapsev1alpha1.MautrixSignalEncryption{
+					Allow: true,
+					KeySharing: &synapsev1alpha1.MautrixSignalEncryptionKeySharing{
+						Allow:               true,
+						RequireCrossSigning: true,
+					},
+				}
+			})
+
+			It("Should configure the bridge encryption section", func() {
+				bridge, ok := config["bridge"].(map[interface{}]interface{})
+				Expect(ok).Should(BeTrue())
+				Expect(bridge["encryption"]).Should(HaveKeyWithValue("allow", true))
+
+				encryption, ok := bridge["encryption"].(map[interface{}]interface{})
+				Expect(ok).Should(BeTrue())
+				Expect(encryption["key_sharing"]).Should(HaveKeyWithValue("allow", true))
+				Expect(encryption["key_sharing"]).Should(HaveKeyWithValue("require_cross_signing", true))
+				Expect(encryption["key_sharing"]).Should(HaveKeyWithValue("require_verification", true))
+			})
+		})
+
+		When("when custom management room messages are set", func() {
+			BeforeEach(func() {
+				ms.Spec.ManagementRoomText = &synapsev1alpha1.MautrixSignalManagementRoomText{
+					Welcome:        "Welcome to the Example Signal bridge!",
+					AdditionalHelp: "Contact @support:example.com for help.",
+				}
+			})
+
+			It("Should override only the given messages", func() {
+				bridge, ok := config["bridge"].(map[interface{}]interface{})
+				Expect(ok).Should(BeTrue())
+
+				text := bridge["management_room_text"]
+				Expect(text).Should(HaveKeyWithValue("welcome", "Welcome to the Example Signal bridge!"))
+				Expect(text).Should(HaveKeyWithValue("additional_help", "Contact @support:example.com for help."))
+				Expect(text).Should(HaveKeyWithValue("welcome_connected", "Use 'help' for help."))
+			})
+		})
+
+		When("when the homeserver connection options are set", func() {
+			BeforeEach(func() {
+				ms.Spec.Homeserver = &synapsev1alpha1.MautrixSignalHomeserver{
+					ConnectionLimit: 250,
+				}
+			})
+
+			It("Should configure the homeserver connection options", func() {
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("connection_limit", 250))
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("http_retry_count", 4))
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("async_media", false))
+			})
+		})
+
+		When("when async media is enabled", func() {
+			BeforeEach(func() {
+				ms.Spec.Homeserver = &synapsev1alpha1.MautrixSignalHomeserver{
+					AsyncMedia: true,
+				}
+			})
+
+			It("Should enable async media uploads", func() {
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("async_media", true))
+			})
+		})
+
+		It("Should keep delivery receipts and message status events disabled by default", func() {
+			Expect(config["bridge"]).Should(HaveKeyWithValue("delivery_receipts", false))
+			Expect(config["bridge"]).Should(HaveKeyWithValue("message_status_events", false))
+		})
+
+		When("when delivery receipts and message status events are enabled", func() {
+			BeforeEach(func() {
+				ms.Spec.DeliveryReceipts = true
+				ms.Spec.MessageStatusEvents = true
+			})
+
+			It("Should enable them in the bridge section", func() {
+				Expect(config["bridge"]).Should(HaveKeyWithValue("delivery_receipts", true))
+				Expect(config["bridge"]).Should(HaveKeyWithValue("message_status_events", true))
+			})
+		})
+
+		When("when the homeserver software is set", func() {
+			BeforeEach(func() {
+				ms.Spec.Homeserver = &synapsev1alpha1.MautrixSignalHomeserver{
+					Software: "asmux",
+				}
+			})
+
+			It("Should configure the homeserver software", func() {
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("software", "asmux"))
+				Expect(config["homeserver"]).Should(HaveKeyWithValue("asmux", true))
+			})
+		})
+
+		When("when ephemeral events are enabled", func() {
+			BeforeEach(func() {
+				ms.Spec.EphemeralEvents = true
+			})
+
+			It("Should receive ephemeral events via appservice transactions", func() {
+				Expect(config["appservice"]).Should(HaveKeyWithValue("ephemeral_events", true))
+				Expect(config["bridge"]).Should(HaveKeyWithValue("sync_with_custom_puppets", false))
+			})
+		})
+
+		It("Should use absolute paths on the signald PVC", func() {
+			Expect(config["signal"]).Should(HaveKeyWithValue("socket_path", "/signald/signald.sock"))
+			Expect(config["signal"]).Should(HaveKeyWithValue("data_dir", "/signald/data"))
+			Expect(config["signal"]).Should(HaveKeyWithValue("avatar_dir", "/signald/avatars"))
+		})
+
+		It("Should keep the default command prefix", func() {
+			Expect(config["bridge"]).Should(HaveKeyWithValue("command_prefix", "!signal"))
+		})
+
+		When("when a custom command prefix is set", func() {
+			BeforeEach(func() {
+				ms.Spec.CommandPrefix = "!sig"
+			})
+
+			It("Should configure the bridge command prefix", func() {
+				Expect(config["bridge"]).Should(HaveKeyWithValue("command_prefix", "!sig"))
+			})
+		})
+
+		When("when relay mode is enabled with custom message formats", func() {
+			BeforeEach(func() {
+				ms.Spec.Relay = &synapsev1alpha1.MautrixSignalRelay{
+					Enabled: true,
+					MessageFormats: map[string]string{
+						"m.text": "<$sender_mxid> $message",
+					},
+				}
+			})
+
+			It("Should configure the bridge relay section", func() {
+				bridge, ok := config["bridge"].(map[interface{}]interface{})
+				Expect(ok).Should(BeTrue())
+				Expect(bridge["relay"]).Should(HaveKeyWithValue("enabled", true))
+
+				relay, ok := bridge["relay"].(map[interface{}]interface{})
+				Expect(ok).Should(BeTrue())
+				Expect(relay["message_formats"]).Should(HaveKeyWithValue("m.text", "<$sender_mxid> $message"))
+				Expect(relay["message_formats"]).Should(HaveKeyWithValue("m.notice", "$sender_displayname: $message"))
+			})
+		})
+	})
+
+	Context("When creating the mautrix-signal and signald PVCs", func() {
+		var r MautrixSignalReconciler
+		var ms synapsev1alpha1.MautrixSignal
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r = MautrixSignalReconciler{Scheme: scheme}
+
+			ms = synapsev1alpha1.MautrixSignal{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-mautrixsignal", Namespace: "test-namespace"},
+				Spec: synapsev1alpha1.MautrixSignalSpec{
+					Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{Name: "test-synapse"},
+				},
+			}
+		})
+
+		It("Should leave the storage class unset by default", func() {
+			pvc, err := r.persistentVolumeClaimForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pvc.Spec.StorageClassName).Should(BeNil())
+		})
+
+		It("Should use the given storage class", func() {
+			storageClassName := "cheap-hdd"
+			ms.Spec.Storage = &synapsev1alpha1.MautrixSignalStorage{StorageClassName: &storageClassName}
+
+			pvc, err := r.persistentVolumeClaimForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*pvc.Spec.StorageClassName).Should(Equal("cheap-hdd"))
+
+			pvc, err = r.persistentVolumeClaimForSignald(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*pvc.Spec.StorageClassName).Should(Equal("cheap-hdd"))
+		})
+	})
+
+	Context("When creating the mautrix-signal and signald Deployments", func() {
+		var r MautrixSignalReconciler
+		var ms synapsev1alpha1.MautrixSignal
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r = MautrixSignalReconciler{Scheme: scheme}
+
+			ms = synapsev1alpha1.MautrixSignal{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-mautrixsignal", Namespace: "test-namespace"},
+				Spec: synapsev1alpha1.MautrixSignalSpec{
+					Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{Name: "test-synapse"},
+				},
+			}
+		})
+
+		It("Should use the data volumes as working directory and HOME by default", func() {
+			dep, err := r.deploymentForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].WorkingDir).Should(Equal("/data"))
+			Expect(dep.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: "HOME", Value: "/data"}))
+
+			dep, err = r.deploymentForSignald(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].WorkingDir).Should(Equal("/signald"))
+			Expect(dep.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: "HOME", Value: "/signald"}))
+		})
+
+		It("Should use a custom working directory without setting HOME", func() {
+			setHome := false
+			ms.Spec.WorkingDir = "home/bridge"
+			ms.Spec.SetHome = &setHome
+
+			dep, err := r.deploymentForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].WorkingDir).Should(Equal("/data/home/bridge"))
+			Expect(dep.Spec.Template.Spec.Containers[0].Env).Should(BeEmpty())
+		})
+
+		It("Should use the image pull Secrets in both Deployments", func() {
+			ms.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry-credentials"}}
+
+			dep, err := r.deploymentForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.ImagePullSecrets).Should(Equal(ms.Spec.ImagePullSecrets))
+
+			dep, err = r.deploymentForSignald(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.ImagePullSecrets).Should(Equal(ms.Spec.ImagePullSecrets))
+		})
+
+		It("Should override the operator default requests with the ones of the Spec", func() {
+			r.DefaultRequests = corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			}
+			ms.Spec.DefaultRequests = corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			}
+			expected := corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			}
+
+			dep, err := r.deploymentForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources.Requests).Should(Equal(expected))
+
+			dep, err = r.deploymentForSignald(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources.Requests).Should(Equal(expected))
+		})
+
+		It("Should apply the scheduling constraints to both Deployments", func() {
+			ms.Spec.NodeSelector = map[string]string{"disktype": "ssd"}
+			ms.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+			ms.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}}
+
+			for _, build := range []func(*synapsev1alpha1.MautrixSignal, metav1.ObjectMeta) (*appsv1.Deployment, error){
+				r.deploymentForMautrixSignal,
+				r.deploymentForSignald,
+			} {
+				dep, err := build(&ms, ms.ObjectMeta)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(dep.Spec.Template.Spec.NodeSelector).Should(Equal(ms.Spec.NodeSelector))
+				Expect(dep.Spec.Template.Spec.Tolerations).Should(Equal(ms.Spec.Tolerations))
+				Expect(dep.Spec.Template.Spec.Affinity).Should(Equal(ms.Spec.Affinity))
+			}
+		})
+
+		It("Should not set a preStop hook by default", func() {
+			dep, err := r.deploymentForMautrixSignal(&ms, ms.ObjectMeta)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.TerminationGracePeriodSeconds).Should(BeNil())
+			Expect(dep.Spec.Template.Spec.Containers[0].Lifecycle).Should(BeNil())
+		})
+
+		It("Should drain the bridge and signald containers on shutdown", func() {
+			gracePeriod := int64(60)
+			ms.Spec.Shutdown = &synapsev1alpha1.MautrixSignalShutdown{
+				DrainSeconds:                  10,
+				TerminationGracePeriodSeconds: &gracePeriod,
+			}
+
+			for _, build := range []func(*synapsev1alpha1.MautrixSignal, metav1.ObjectMeta) (*appsv1.Deployment, error){
+				r.deploymentForMautrixSignal,
+				r.deploymentForSignald,
+			} {
+				dep, err := build(&ms, ms.ObjectMeta)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(dep.Spec.Template.Spec.TerminationGracePeriodSeconds).Should(Equal(&gracePeriod))
+				Expect(dep.Spec.Template.Spec.Containers[0].Lifecycle).ShouldNot(BeNil())
+				Expect(dep.Spec.Template.Spec.Containers[0].Lifecycle.PreStop.Exec.Command).Should(Equal(
+					[]string{"/bin/sh", "-c", "sleep 10"},
+				))
+			}
+		})
+	})
+
+	Context("When checking that the bridge can reach Synapse", func() {
+		It("Should run the self-test Job with the labels and image of the mautrix-signal Pods", func() {
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r := MautrixSignalReconciler{
+				Scheme:          scheme,
+				DefaultRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			}
+
+			ms := synapsev1alpha1.MautrixSignal{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-mautrixsignal", Namespace: "test-namespace"},
+				Spec: synapsev1alpha1.MautrixSignalSpec{
+					Synapse:          synapsev1alpha1.MautrixSignalSynapseSpec{Name: "test-synapse"},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-credentials"}},
+				},
+			}
+
+			job, err := r.jobForSynapseConnectivity(&ms, metav1.ObjectMeta{Name: GetMautrixSignalSelfTestResourceName(ms), Namespace: ms.Namespace})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(job.Name).Should(Equal("test-mautrixsignal-selftest"))
+			Expect(job.Namespace).Should(Equal("test-namespace"))
+			Expect(job.Spec.Template.Labels).Should(Equal(labelsForMautrixSignal(ms.Name)))
+			Expect(job.Spec.Template.Spec.ImagePullSecrets).Should(Equal(ms.Spec.ImagePullSecrets))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).Should(Equal(mautrixSignalImage))
+			Expect(job.Spec.Template.Spec.Containers[0].Args).Should(Equal([]string{
+				"http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions",
+			}))
+			Expect(job.Spec.Template.Spec.Containers[0].Resources.Requests).Should(Equal(r.DefaultRequests))
+			Expect(metav1.IsControlledBy(job, &ms)).Should(BeTrue())
+		})
+	})
+
+	Context("When validating the MautrixSignal Spec", func() {
+		var spec synapsev1alpha1.MautrixSignalSpec
+
+		BeforeEach(func() {
+			spec = synapsev1alpha1.MautrixSignalSpec{
+				Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{Name: "test-synapse"},
+			}
+		})
+
+		It("Should accept a minimal Spec", func() {
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).Should(Succeed())
+		})
+
+		It("Should accept a Synapse instance in the same namespace", func() {
+			spec.Synapse.Namespace = "test-namespace"
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).Should(Succeed())
+		})
+
+		It("Should reject a Synapse instance in another namespace", func() {
+			spec.Synapse.Namespace = "other-namespace"
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).ShouldNot(Succeed())
+		})
+
+		It("Should reject key sharing when encryption is not allowed", func() {
+			spec.Encryption = &synapsev1alpha1.MautrixSignalEncryption{
+				Allow:      false,
+				KeySharing: &synapsev1alpha1.MautrixSignalEncryptionKeySharing{Allow: true},
+			}
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).ShouldNot(Succeed())
+		})
+
+		It("Should reject an unknown homeserver software", func() {
+			spec.Homeserver = &synapsev1alpha1.MautrixSignalHomeserver{Software: "dendrite"}
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).ShouldNot(Succeed())
+
+			spec.Homeserver.Software = "hungry"
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).Should(Succeed())
+		})
+
+		It("Should reject a drain period not shorter than the grace period", func() {
+			spec.Shutdown = &synapsev1alpha1.MautrixSignalShutdown{DrainSeconds: 30}
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).ShouldNot(Succeed())
+
+			gracePeriod := int64(45)
+			spec.Shutdown.TerminationGracePeriodSeconds = &gracePeriod
+			Expect(validateMautrixSignalSpec(spec, "test-namespace")).Should(Succeed())
+		})
+	})
+
+	Context("When filtering the Synapse updates relevant to the bridges", func() {
+		var oldSynapse, newSynapse *synapsev1alpha1.Synapse
+
+		BeforeEach(func() {
+			oldSynapse = &synapsev1alpha1.Synapse{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-synapse", Generation: 1},
+				Status: synapsev1alpha1.SynapseStatus{
+					State: "RUNNING",
+					HomeserverConfiguration: synapsev1alpha1.SynapseStatusHomeserverConfiguration{
+						ServerName: "example.com",
+					},
+				},
+			}
+			newSynapse = oldSynapse.DeepCopy()
+		})
+
+		It("Should ignore Status updates unrelated to the bridges", func() {
+			newSynapse.Status.NeedsReconcile = true
+			Expect(synapseConfigurationChanged().Update(event.UpdateEvent{
+				ObjectOld: oldSynapse,
+				ObjectNew: newSynapse,
+			})).Should(BeFalse())
+		})
+
+		It("Should trigger on a server name change", func() {
+			newSynapse.Status.HomeserverConfiguration.ServerName = "matrix.example.com"
+			Expect(synapseConfigurationChanged().Update(event.UpdateEvent{
+				ObjectOld: oldSynapse,
+				ObjectNew: newSynapse,
+			})).Should(BeTrue())
+		})
+
+		It("Should trigger on a Spec change", func() {
+			newSynapse.Generation = 2
+			Expect(synapseConfigurationChanged().Update(event.UpdateEvent{
+				ObjectOld: oldSynapse,
+				ObjectNew: newSynapse,
+			})).Should(BeTrue())
+		})
+	})
+
+	Context("When listing the endpoints served by the bridge", func() {
+		var ms synapsev1alpha1.MautrixSignal
+
+		BeforeEach(func() {
+			ms = synapsev1alpha1.MautrixSignal{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-mautrixsignal", Namespace: "test-namespace"},
+			}
+		})
+
+		It("Should list the provisioning API under its prefix", func() {
+			config := map[string]interface{}{
+				"bridge": map[interface{}]interface{}{
+					"provisioning": map[interface{}]interface{}{
+						"enabled": true,
+						"prefix":  "/_matrix/provision",
+					},
+				},
+			}
+			Expect(endpointsForMautrixSignal(&ms, config)).Should(Equal(map[string]string{
+				"appservice":   "http://test-mautrixsignal.test-namespace.svc.cluster.local:29328",
+				"provisioning": "http://test-mautrixsignal.test-namespace.svc.cluster.local:29328/_matrix/provision",
+			}))
+		})
+
+		It("Should not list the provisioning API when it is disabled", func() {
+			config := map[string]interface{}{"bridge": map[interface{}]interface{}{}}
+			Expect(endpointsForMautrixSignal(&ms, config)).Should(Equal(map[string]string{
+				"appservice": "http://test-mautrixsignal.test-namespace.svc.cluster.local:29328",
+			}))
+		})
+
+		It("Should list and expose the metrics when they are enabled", func() {
+			config := map[string]interface{}{
+				"bridge": map[interface{}]interface{}{},
+				"metrics": map[interface{}]interface{}{
+					"enabled":     true,
+					"listen_port": 8001,
+				},
+			}
+			Expect(endpointsForMautrixSignal(&ms, config)).Should(Equal(map[string]string{
+				"appservice": "http://test-mautrixsignal.test-namespace.svc.cluster.local:29328",
+				"metrics":    "http://test-mautrixsignal.test-namespace.svc.cluster.local:8001/metrics",
+			}))
+
+			scheme := runtime.NewScheme()
+			Expect(synapsev1alpha1.AddToScheme(scheme)).Should(Succeed())
+			r := MautrixSignalReconciler{Scheme: scheme}
+
+			service, err := r.serviceForMautrixSignal(&ms, ms.ObjectMeta, mautrixSignalMetricsPort(config))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(service.Spec.Ports).Should(HaveLen(2))
+			Expect(service.Spec.Ports[1].Name).Should(Equal("metrics"))
+			Expect(service.Spec.Ports[1].Port).Should(BeEquivalentTo(8001))
+		})
+
+		It("Should not expose the metrics when they are disabled", func() {
+			config := map[string]interface{}{
+				"metrics": map[interface{}]interface{}{"enabled": false, "listen_port": 8000},
+			}
+			Expect(mautrixSignalMetricsPort(config)).Should(BeZero())
+		})
+	})
+})
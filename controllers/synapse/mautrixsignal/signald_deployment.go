@@ -27,6 +27,7 @@ import (
 	"github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
 	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
 // labelsForSignald returns the labels for selecting the resources
@@ -57,6 +58,7 @@ func (r *MautrixSignalReconciler) reconcileSignaldDeployment(ctx context.Context
 		r.Client,
 		desiredDeployment,
 		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentScheduling(desiredDeployment),
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -82,6 +84,23 @@ func (r *MautrixSignalReconciler) deploymentForSignald(ms *synapsev1alpha1.Mautr
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: ms.Spec.ImagePullSecrets,
+					// The init container creates the signald data and avatar
+					// directories on the PVC, so that they exist before the
+					// bridge references them in its config.yaml. signald
+					// stores the accounts auth data in /signald/data, which
+					// must be persisted to avoid re-linking the accounts
+					// after a restart.
+					InitContainers: []corev1.Container{{
+						Image: "registry.access.redhat.com/ubi8/ubi-minimal:8.7",
+						Name:  "initdatadir",
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "signald",
+							MountPath: "/signald",
+						}},
+						Command: []string{"bin/sh", "-c"},
+						Args:    []string{"mkdir -p /signald/data /signald/avatars"},
+					}},
 					Containers: []corev1.Container{{
 						Image: "docker.io/signald/signald:0.23.0",
 						Name:  "signald",
@@ -102,6 +121,12 @@ func (r *MautrixSignalReconciler) deploymentForSignald(ms *synapsev1alpha1.Mautr
 			},
 		},
 	}
+	setContainerWorkingDir(ms, &dep.Spec.Template.Spec.Containers[0], "/signald")
+	setShutdownDrain(ms, &dep.Spec.Template.Spec)
+	setScheduling(ms, &dep.Spec.Template.Spec)
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, utils.MergeDefaultRequests(r.DefaultRequests, ms.Spec.DefaultRequests))
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(ms, dep, r.Scheme); err != nil {
 		return &appsv1.Deployment{}, err
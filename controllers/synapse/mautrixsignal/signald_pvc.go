@@ -46,12 +46,7 @@ func (r *MautrixSignalReconciler) reconcileSignaldPVC(ctx context.Context, req c
 		return subreconciler.RequeueWithError(err)
 	}
 
-	if err := reconcile.ReconcileResource(
-		ctx,
-		r.Client,
-		desiredPVC,
-		&corev1.PersistentVolumeClaim{},
-	); err != nil {
+	if err := reconcile.ReconcilePersistentVolumeClaim(ctx, r.Client, desiredPVC); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
 
@@ -72,6 +67,7 @@ func (r *MautrixSignalReconciler) persistentVolumeClaimForSignald(ms *synapsev1a
 					"storage": *resource.NewQuantity(5*1024*1024*1024, resource.BinarySI),
 				},
 			},
+			StorageClassName: storageClassNameForMautrixSignal(ms),
 		},
 	}
 
@@ -0,0 +1,198 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+)
+
+// GetInlineHeisenbridgeResourceName returns the name of the Heisenbridge
+// object created for the inline bridge configuration of the given Synapse.
+func GetInlineHeisenbridgeResourceName(s synapsev1alpha1.Synapse) string {
+	return s.Name + "-heisenbridge"
+}
+
+// GetInlineMautrixSignalResourceName returns the name of the MautrixSignal
+// object created for the inline bridge configuration of the given Synapse.
+func GetInlineMautrixSignalResourceName(s synapsev1alpha1.Synapse) string {
+	return s.Name + "-mautrixsignal"
+}
+
+// reconcileInlineHeisenbridge is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It creates or updates the Heisenbridge object defined inline in the
+// Synapse Spec, or deletes it if the inline bridge is disabled.
+func (r *SynapseReconciler) reconcileInlineHeisenbridge(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetInlineHeisenbridgeResourceName(*s), s.Namespace, map[string]string{})
+
+	if s.Spec.Bridges == nil || s.Spec.Bridges.Heisenbridge == nil || !s.Spec.Bridges.Heisenbridge.Enabled {
+		if err := r.deleteInlineBridge(ctx, s, &synapsev1alpha1.Heisenbridge{ObjectMeta: objectMeta}); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.ContinueReconciling()
+	}
+
+	desiredHeisenbridge, err := r.heisenbridgeForSynapse(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredHeisenbridge,
+		&synapsev1alpha1.Heisenbridge{},
+		func(current client.Object) {
+			// The inline bridge is entirely defined by the Synapse Spec
+			current.(*synapsev1alpha1.Heisenbridge).Spec = desiredHeisenbridge.Spec
+		},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// heisenbridgeForSynapse returns a Heisenbridge object built from the inline
+// bridge configuration of the given Synapse
+func (r *SynapseReconciler) heisenbridgeForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*synapsev1alpha1.Heisenbridge, error) {
+	inline := s.Spec.Bridges.Heisenbridge
+
+	h := &synapsev1alpha1.Heisenbridge{
+		ObjectMeta: objectMeta,
+		Spec: synapsev1alpha1.HeisenbridgeSpec{
+			VerboseLevel:     inline.VerboseLevel,
+			ImagePullSecrets: s.Spec.ImagePullSecrets,
+			DefaultRequests:  s.Spec.DefaultRequests,
+			Synapse: synapsev1alpha1.HeisenbridgeSynapseSpec{
+				Name:      s.Name,
+				Namespace: s.Namespace,
+			},
+		},
+	}
+	if inline.ConfigMap != nil {
+		h.Spec.ConfigMap = *inline.ConfigMap
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, h, r.Scheme); err != nil {
+		return &synapsev1alpha1.Heisenbridge{}, err
+	}
+	return h, nil
+}
+
+// reconcileInlineMautrixSignal is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It creates or updates the MautrixSignal object defined inline in the
+// Synapse Spec, or deletes it if the inline bridge is disabled.
+func (r *SynapseReconciler) reconcileInlineMautrixSignal(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetInlineMautrixSignalResourceName(*s), s.Namespace, map[string]string{})
+
+	if s.Spec.Bridges == nil || s.Spec.Bridges.MautrixSignal == nil || !s.Spec.Bridges.MautrixSignal.Enabled {
+		if err := r.deleteInlineBridge(ctx, s, &synapsev1alpha1.MautrixSignal{ObjectMeta: objectMeta}); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.ContinueReconciling()
+	}
+
+	desiredMautrixSignal, err := r.mautrixSignalForSynapse(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredMautrixSignal,
+		&synapsev1alpha1.MautrixSignal{},
+		func(current client.Object) {
+			// The inline bridge is entirely defined by the Synapse Spec
+			current.(*synapsev1alpha1.MautrixSignal).Spec = desiredMautrixSignal.Spec
+		},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// mautrixSignalForSynapse returns a MautrixSignal object built from the
+// inline bridge configuration of the given Synapse
+func (r *SynapseReconciler) mautrixSignalForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*synapsev1alpha1.MautrixSignal, error) {
+	inline := s.Spec.Bridges.MautrixSignal
+
+	ms := &synapsev1alpha1.MautrixSignal{
+		ObjectMeta: objectMeta,
+		Spec: synapsev1alpha1.MautrixSignalSpec{
+			ImagePullSecrets: s.Spec.ImagePullSecrets,
+			DefaultRequests:  s.Spec.DefaultRequests,
+			Synapse: synapsev1alpha1.MautrixSignalSynapseSpec{
+				Name:      s.Name,
+				Namespace: s.Namespace,
+			},
+		},
+	}
+	if inline.ConfigMap != nil {
+		ms.Spec.ConfigMap = *inline.ConfigMap
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, ms, r.Scheme); err != nil {
+		return &synapsev1alpha1.MautrixSignal{}, err
+	}
+	return ms, nil
+}
+
+// deleteInlineBridge deletes the given bridge object if it exists and is
+// controlled by the Synapse instance. Bridge objects created by the user are
+// left untouched.
+func (r *SynapseReconciler) deleteInlineBridge(ctx context.Context, s *synapsev1alpha1.Synapse, bridge client.Object) error {
+	key := client.ObjectKeyFromObject(bridge)
+	if err := r.Get(ctx, key, bridge); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !metav1.IsControlledBy(bridge, s) {
+		return nil
+	}
+
+	if err := r.Delete(ctx, bridge); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -18,7 +18,13 @@ package synapse
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"net"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -36,6 +42,13 @@ import (
 	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
+// Port of the Synapse metrics listener, if Spec.Metrics is enabled
+const synapseMetricsPort = 9000
+
+// Path at which the dedicated media store PVC is mounted in the Synapse
+// container, if Spec.Storage.MediaStore is set
+const synapseMediaStorePath = "/media_store"
+
 // reconcileSynapseConfigMap is a function of type FnWithRequest, to be
 // called in the main reconciliation loop.
 //
@@ -47,6 +60,11 @@ func (r *SynapseReconciler) reconcileSynapseConfigMap(ctx context.Context, req c
 		return r, err
 	}
 
+	if len(s.Spec.Homeserver.Values.FederationMetricsDomains) > 0 && !isMetricsEnabled(s) {
+		log := ctrllog.FromContext(ctx)
+		log.Info("FederationMetricsDomains is set but metrics are not enabled. No federation metrics will be exposed.")
+	}
+
 	objectMetaForSynapse := reconcile.SetObjectMeta(s.Name, s.Namespace, map[string]string{})
 
 	desiredConfigMap, err := r.configMapForSynapse(s, objectMetaForSynapse)
@@ -68,7 +86,38 @@ func (r *SynapseReconciler) reconcileSynapseConfigMap(ctx context.Context, req c
 
 // configMapForSynapse returns a synapse ConfigMap object
 func (r *SynapseReconciler) configMapForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
-	homeserverYaml := `
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data:       map[string]string{"homeserver.yaml": homeserverYAMLForSynapse(s)},
+	}
+	if isAdminListenerEnabled(s) {
+		cm.Data[adminAPIBlockerModule+".py"] = adminAPIBlockerSource
+	}
+
+	// Make sure the rendered template is a valid document before going any
+	// further, rather than shipping a ConfigMap Synapse can't parse
+	if err := validateGeneratedHomeserver(*cm, s.Spec.Homeserver.Values.ServerName); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	// Apply the optional configuration options defined in
+	// Spec.Homeserver.Values
+	if err := utils.UpdateConfigMapData(cm, s, r.updateHomeserverWithValues, "homeserver.yaml", utils.HomeserverSchema); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	return cm, nil
+}
+
+// homeserverYAMLForSynapse renders the default homeserver.yaml template for
+// the given Synapse instance
+func homeserverYAMLForSynapse(s *synapsev1alpha1.Synapse) string {
+	return `
 # Configuration file for Synapse.
 #
 # This is a YAML file: see [1] for a quick introduction. Note in particular
@@ -1268,7 +1317,7 @@ account_validity:
 # If set, allows registration of standard or admin accounts by anyone who
 # has the shared secret, even if registration is otherwise disabled.
 #
-registration_shared_secret: ":Cc*s^6_Xm*zcxu.jcxJXN=zGFaMzaUgmsP^gnCRFYg3,Tacsx"
+#registration_shared_secret: <PRIVATE STRING>
 
 # Set the number of bcrypt rounds used to generate password hash.
 # Larger numbers increase the work factor needed to generate the hash.
@@ -1484,13 +1533,13 @@ report_stats: ` + utils.BoolToString(s.Spec.Homeserver.Values.ReportStats) + `
 # the registration_shared_secret is used, if one is given; otherwise,
 # a secret key is derived from the signing key.
 #
-macaroon_secret_key: "EVr3uuImrTyxDVY1ukw*;r^zu1Y#8UkAp0@Bl8i9rzi~-+n95;"
+#macaroon_secret_key: <PRIVATE STRING>
 
 # a secret which is used to calculate HMACs for form values, to stop
 # falsification of values. Must be specified for the User Consent
 # forms to work.
 #
-form_secret: "uD#~UE2pAzLUQIvj8x1;0iCzNL-UcUs1._WtUGXHRp@1Ogmyg4"
+#form_secret: <PRIVATE STRING>
 
 ## Signing Keys ##
 
@@ -1669,8 +1718,8 @@ saml2_config:
     #contact_person:
     #  - given_name: Bob
     #    sur_name: "the Sysadmin"
-    #    email_address": ["admin@example.com"]
-    #    contact_type": technical
+    #    email_address: ["admin@example.com"]
+    #    contact_type: technical
 
   # Instead of putting the config inline as above, you can specify a
   # separate pysaml2 configuration file:
@@ -2681,7 +2730,10 @@ opentracing:
 #
 # By default this is unused and traffic is not authenticated.
 #
-#worker_replication_secret: "
+# The operator generates this secret and stores it in the
+# <synapse-name>-replication Secret.
+#
+#worker_replication_secret: ""
 
 
 # Configuration for Redis when using workers. This *must* be enabled when
@@ -2705,18 +2757,675 @@ redis:
 
   # vim:ft=yaml
   `
+}
 
-	cm := &corev1.ConfigMap{
-		ObjectMeta: objectMeta,
-		Data:       map[string]string{"homeserver.yaml": homeserverYaml},
+// validateGeneratedHomeserver checks that the homeserver.yaml generated by
+// the operator can be parsed, and that the server_name it holds is the one
+// from the Synapse Spec. A mismatch indicates the value was not correctly
+// escaped in the template.
+func validateGeneratedHomeserver(cm corev1.ConfigMap, serverName string) error {
+	homeserver, err := utils.LoadYAMLFileFromConfigMapData(cm, "homeserver.yaml")
+	if err != nil {
+		return errors.New("generated homeserver.yaml is not valid YAML: " + err.Error())
 	}
 
-	// Set Synapse instance as the owner and controller
-	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
-		return &corev1.ConfigMap{}, err
+	if homeserver["server_name"] != serverName {
+		return errors.New("generated homeserver.yaml has an unexpected server_name, check the value of spec.homeserver.values.serverName")
 	}
 
-	return cm, nil
+	return nil
+}
+
+// updateHomeserverWithValues is a function of type updateDataFunc function to
+// be passed as an argument in a call to utils.UpdateConfigMapData.
+//
+// It configures homeserver.yaml with the optional values defined in
+// Spec.Homeserver.Values. Options left empty are not written, and Synapse's
+// defaults apply.
+func (r *SynapseReconciler) updateHomeserverWithValues(
+	obj client.Object,
+	homeserver map[string]interface{},
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+	values := s.Spec.Homeserver.Values
+
+	// Configure the report stats endpoint explicitly, so that Synapse
+	// doesn't warn about a missing endpoint
+	if values.ReportStats {
+		homeserver["report_stats_endpoint"] = reportStatsEndpoint(values)
+	}
+	if len(values.AliasCreationRules) > 0 {
+		homeserver["alias_creation_rules"] = directoryRulesToHomeserver(values.AliasCreationRules)
+	}
+	if len(values.RoomListPublicationRules) > 0 {
+		homeserver["room_list_publication_rules"] = directoryRulesToHomeserver(values.RoomListPublicationRules)
+	}
+	if values.EnableRoomListSearch != nil {
+		homeserver["enable_room_list_search"] = *values.EnableRoomListSearch
+	}
+	if values.AllowPublicRoomsOverFederation != nil {
+		homeserver["allow_public_rooms_over_federation"] = *values.AllowPublicRoomsOverFederation
+	}
+	if values.AllowPublicRoomsWithoutAuth != nil {
+		homeserver["allow_public_rooms_without_auth"] = *values.AllowPublicRoomsWithoutAuth
+	}
+	if len(values.FederationMetricsDomains) > 0 {
+		homeserver["federation_metrics_domains"] = values.FederationMetricsDomains
+	}
+	if len(values.GCThresholds) > 0 {
+		homeserver["gc_thresholds"] = values.GCThresholds
+	}
+	if len(values.ExperimentalFeatures) > 0 {
+		homeserver["experimental_features"] = values.ExperimentalFeatures
+	}
+	if values.MetricsFlags != nil && values.MetricsFlags.KnownServers {
+		homeserver["metrics_flags"] = map[string]bool{"known_servers": true}
+	}
+	if values.MaxUploadSize != "" {
+		homeserver["max_upload_size"] = values.MaxUploadSize
+	}
+	if values.EnablePresence != nil && !*values.EnablePresence {
+		homeserver["use_presence"] = false
+	}
+	if values.DefaultRoomVersion != "" {
+		homeserver["default_room_version"] = values.DefaultRoomVersion
+	}
+	if len(values.TrustedKeyServers) > 0 {
+		homeserver["trusted_key_servers"] = trustedKeyServersToHomeserver(values.TrustedKeyServers)
+	}
+	if values.SuppressKeyServerWarning {
+		homeserver["suppress_key_server_warning"] = true
+	}
+	if values.RateLimiting != nil {
+		if err := rateLimitingToHomeserver(values.RateLimiting, homeserver); err != nil {
+			return err
+		}
+	}
+	if values.Retention != nil && values.Retention.Enabled {
+		homeserver["retention"] = retentionToHomeserver(values.Retention)
+	}
+	if values.URLPreview != nil {
+		homeserver["url_preview_enabled"] = values.URLPreview.Enabled
+		if values.URLPreview.Enabled {
+			homeserver["url_preview_ip_range_blacklist"] = urlPreviewIPRangeBlacklist(values.URLPreview)
+		}
+	}
+	if values.Media != nil {
+		homeserver["dynamic_thumbnails"] = values.Media.DynamicThumbnails
+		if len(values.Media.ThumbnailSizes) > 0 {
+			homeserver["thumbnail_sizes"] = thumbnailSizesToHomeserver(values.Media.ThumbnailSizes)
+		}
+		mediaRetention := map[string]interface{}{}
+		if values.Media.LocalMediaLifetime != "" {
+			mediaRetention["local_media_lifetime"] = values.Media.LocalMediaLifetime
+		}
+		if values.Media.RemoteMediaLifetime != "" {
+			mediaRetention["remote_media_lifetime"] = values.Media.RemoteMediaLifetime
+		}
+		if len(mediaRetention) > 0 {
+			homeserver["media_retention"] = mediaRetention
+		}
+	}
+	if len(values.Listeners) > 0 {
+		homeserver["listeners"] = listenersToHomeserver(values.Listeners)
+	} else if values.XForwarded != nil || values.CompressResponses != nil {
+		defaultListener, err := getDefaultListener(homeserver)
+		if err != nil {
+			return err
+		}
+		if values.XForwarded != nil {
+			defaultListener["x_forwarded"] = *values.XForwarded
+		}
+		if values.CompressResponses != nil {
+			resources, _ := defaultListener["resources"].([]interface{})
+			for _, r := range resources {
+				if resource, ok := r.(map[interface{}]interface{}); ok {
+					resource["compress"] = *values.CompressResponses
+				}
+			}
+		}
+	}
+	if values.Manhole != nil && values.Manhole.Enabled {
+		listeners, _ := homeserver["listeners"].([]interface{})
+		homeserver["listeners"] = append(listeners, map[string]interface{}{
+			"port":           manholePort(values.Manhole),
+			"type":           "manhole",
+			"bind_addresses": []string{"127.0.0.1"},
+		})
+	}
+	if isAdminListenerEnabled(s) {
+		// The admin API is served along with the client resource, on every
+		// http listener. It is replaced by a 404 page on the other listeners.
+		listeners, _ := homeserver["listeners"].([]interface{})
+		blockAdminAPI(listeners)
+		homeserver["listeners"] = append(listeners, map[string]interface{}{
+			"port":           adminListenerPort(values.AdminListener),
+			"type":           "http",
+			"bind_addresses": []string{"0.0.0.0"},
+			"resources": []map[string]interface{}{{
+				"names": []string{"client"},
+			}},
+		})
+	}
+	if len(values.Modules) > 0 {
+		modules, err := modulesToHomeserver(values.Modules)
+		if err != nil {
+			return err
+		}
+		homeserver["modules"] = modules
+	}
+	homeserver["enable_registration"] = values.EnableRegistration
+	if isCaptchaEnabled(s) {
+		homeserver["enable_registration_captcha"] = true
+	}
+	if isEmailEnabled(s) {
+		// The SMTP credentials are added in the homeserver secrets file
+		homeserver["email"] = emailToHomeserver(values.Email)
+	}
+	if values.TURN != nil {
+		// The shared secret is added in the homeserver secrets file
+		homeserver["turn_uris"] = values.TURN.URIs
+		if values.TURN.UserLifetime != "" {
+			homeserver["turn_user_lifetime"] = values.TURN.UserLifetime
+		}
+	}
+	if values.PasswordLogin != nil {
+		// Keep the other password_config options, if any
+		passwordConfig, ok := homeserver["password_config"].(map[interface{}]interface{})
+		if !ok {
+			passwordConfig = map[interface{}]interface{}{}
+		}
+		passwordConfig["enabled"] = *values.PasswordLogin
+		homeserver["password_config"] = passwordConfig
+	}
+	if values.OIDC != nil {
+		homeserver["oidc_config"] = oidcToHomeserver(values.OIDC)
+	}
+	if len(values.OIDCProviders) > 0 {
+		homeserver["oidc_providers"] = oidcProvidersToHomeserver(values.OIDCProviders)
+
+		// The singular oidc_config is superseded by oidc_providers
+		if values.OIDC == nil {
+			delete(homeserver, "oidc_config")
+		}
+	}
+
+	return nil
+}
+
+// Default endpoint to report the anonymized homeserver usage statistics to
+const defaultReportStatsEndpoint = "https://matrix.org/report-usage-stats/push"
+
+// reportStatsEndpoint returns the endpoint to report the anonymized
+// homeserver usage statistics to
+func reportStatsEndpoint(values *synapsev1alpha1.SynapseHomeserverValues) string {
+	if values.ReportStatsEndpoint != "" {
+		return values.ReportStatsEndpoint
+	}
+	return defaultReportStatsEndpoint
+}
+
+// experimentalFeatureRegexp matches the MSC flags of the
+// experimental_features section, e.g. msc3440_enabled
+var experimentalFeatureRegexp = regexp.MustCompile(`^msc[0-9]+(_[a-z0-9]+)*$`)
+
+// uploadSizeRegexp matches the sizes accepted by Synapse for
+// max_upload_size, e.g. 100M. Synapse only knows the K and M suffixes.
+var uploadSizeRegexp = regexp.MustCompile(`^[0-9]+[KM]?$`)
+
+// rateLimitingToHomeserver renders the rate limits defined in the Synapse
+// Spec into the rc_message, rc_registration and rc_login sections of
+// homeserver.yaml. Omitted rate limits are left out, so that Synapse's
+// defaults apply.
+func rateLimitingToHomeserver(rateLimiting *synapsev1alpha1.SynapseHomeserverRateLimiting, homeserver map[string]interface{}) error {
+	if rateLimiting.Message != nil {
+		section, err := rateLimitToHomeserver(rateLimiting.Message)
+		if err != nil {
+			return err
+		}
+		homeserver["rc_message"] = section
+	}
+
+	if rateLimiting.Registration != nil {
+		section, err := rateLimitToHomeserver(rateLimiting.Registration)
+		if err != nil {
+			return err
+		}
+		homeserver["rc_registration"] = section
+	}
+
+	if rateLimiting.Login != nil {
+		login := map[string]interface{}{}
+		for key, rateLimit := range map[string]*synapsev1alpha1.SynapseHomeserverRateLimit{
+			"address":         rateLimiting.Login.Address,
+			"account":         rateLimiting.Login.Account,
+			"failed_attempts": rateLimiting.Login.FailedAttempts,
+		} {
+			if rateLimit == nil {
+				continue
+			}
+			section, err := rateLimitToHomeserver(rateLimit)
+			if err != nil {
+				return err
+			}
+			login[key] = section
+		}
+		homeserver["rc_login"] = login
+	}
+
+	return nil
+}
+
+// rateLimitToHomeserver converts a rate limit defined in the Synapse Spec to
+// its per_second and burst_count settings
+func rateLimitToHomeserver(rateLimit *synapsev1alpha1.SynapseHomeserverRateLimit) (map[string]interface{}, error) {
+	section := map[string]interface{}{}
+	if rateLimit.PerSecond != "" {
+		perSecond, err := strconv.ParseFloat(rateLimit.PerSecond, 64)
+		if err != nil {
+			return nil, err
+		}
+		section["per_second"] = perSecond
+	}
+	if rateLimit.BurstCount != nil {
+		section["burst_count"] = *rateLimit.BurstCount
+	}
+	return section, nil
+}
+
+// synapseDurationRegexp matches the durations accepted by Synapse, e.g. 1d.
+// A duration without unit is a number of milliseconds.
+var synapseDurationRegexp = regexp.MustCompile(`^([0-9]+)(ms|s|m|h|d|w|y)?$`)
+
+// synapseDurationUnits maps the units of the Synapse durations to their
+// length
+var synapseDurationUnits = map[string]time.Duration{
+	"":   time.Millisecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseSynapseDuration parses a Synapse duration such as 1d or 12h
+func parseSynapseDuration(duration string) (time.Duration, error) {
+	match := synapseDurationRegexp.FindStringSubmatch(duration)
+	if match == nil {
+		return 0, errors.New("invalid duration " + duration + ", must be a number followed by ms, s, m, h, d, w or y, such as 1d")
+	}
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(value) * synapseDurationUnits[match[2]], nil
+}
+
+// retentionToHomeserver converts the retention policy defined in the Synapse
+// Spec to the format of the retention section of homeserver.yaml
+func retentionToHomeserver(retention *synapsev1alpha1.SynapseHomeserverRetention) map[string]interface{} {
+	section := map[string]interface{}{"enabled": true}
+
+	if retention.DefaultPolicy != nil {
+		defaultPolicy := map[string]string{}
+		if retention.DefaultPolicy.MinLifetime != "" {
+			defaultPolicy["min_lifetime"] = retention.DefaultPolicy.MinLifetime
+		}
+		if retention.DefaultPolicy.MaxLifetime != "" {
+			defaultPolicy["max_lifetime"] = retention.DefaultPolicy.MaxLifetime
+		}
+		section["default_policy"] = defaultPolicy
+	}
+
+	if len(retention.PurgeJobs) > 0 {
+		purgeJobs := []map[string]string{}
+		for _, job := range retention.PurgeJobs {
+			purgeJob := map[string]string{"interval": job.Interval}
+			if job.ShortestMaxLifetime != "" {
+				purgeJob["shortest_max_lifetime"] = job.ShortestMaxLifetime
+			}
+			if job.LongestMaxLifetime != "" {
+				purgeJob["longest_max_lifetime"] = job.LongestMaxLifetime
+			}
+			purgeJobs = append(purgeJobs, purgeJob)
+		}
+		section["purge_jobs"] = purgeJobs
+	}
+
+	return section
+}
+
+// trustedKeyServersToHomeserver converts the trusted key servers defined in
+// the Synapse Spec to the format of the trusted_key_servers section of
+// homeserver.yaml
+func trustedKeyServersToHomeserver(keyServers []synapsev1alpha1.SynapseHomeserverKeyServer) []map[string]interface{} {
+	trustedKeyServers := []map[string]interface{}{}
+	for _, keyServer := range keyServers {
+		trustedKeyServer := map[string]interface{}{"server_name": keyServer.ServerName}
+		if len(keyServer.VerifyKeys) > 0 {
+			trustedKeyServer["verify_keys"] = keyServer.VerifyKeys
+		}
+		trustedKeyServers = append(trustedKeyServers, trustedKeyServer)
+	}
+	return trustedKeyServers
+}
+
+// Private, loopback and reserved IP ranges which the URL preview spider is
+// denied from accessing by default, as recommended by Synapse
+var defaultURLPreviewIPRangeBlacklist = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"169.254.0.0/16",
+	"198.18.0.0/15",
+	"192.0.2.0/24",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"::1/128",
+	"fe80::/10",
+	"fc00::/7",
+}
+
+// urlPreviewIPRangeBlacklist returns the IP ranges which the URL preview
+// spider is denied from accessing. Synapse refuses to start with URL
+// previews enabled but no blacklist.
+func urlPreviewIPRangeBlacklist(urlPreview *synapsev1alpha1.SynapseHomeserverURLPreview) []string {
+	if len(urlPreview.IPRangeBlacklist) > 0 {
+		return urlPreview.IPRangeBlacklist
+	}
+	return defaultURLPreviewIPRangeBlacklist
+}
+
+// Name of the Python module, shipped in the Synapse ConfigMap when the admin
+// listener is enabled, which replaces the admin API on the other listeners
+const adminAPIBlockerModule = "synapse_admin_api_blocker"
+
+// Source of adminAPIBlockerModule. Synapse instantiates the resources listed
+// in the additional_resources of a listener with their config and the module
+// API.
+const adminAPIBlockerSource = `from twisted.web.resource import NoResource
+
+
+class AdminAPIBlocker(NoResource):
+    def __init__(self, config, module_api):
+        super().__init__("The admin API is only served on the admin listener")
+`
+
+// blockAdminAPI replaces /_synapse/admin with a 404 page on the given
+// homeserver.yaml http listeners
+func blockAdminAPI(listeners []interface{}) {
+	blocker := map[string]interface{}{
+		"/_synapse/admin": map[string]interface{}{
+			"module": adminAPIBlockerModule + ".AdminAPIBlocker",
+		},
+	}
+
+	for _, l := range listeners {
+		switch listener := l.(type) {
+		case map[interface{}]interface{}:
+			if listener["type"] == "http" {
+				listener["additional_resources"] = blocker
+			}
+		case map[string]interface{}:
+			if listener["type"] == "http" {
+				listener["additional_resources"] = blocker
+			}
+		}
+	}
+}
+
+// isAdminListenerEnabled returns true if a dedicated listener serves the
+// admin API
+func isAdminListenerEnabled(s *synapsev1alpha1.Synapse) bool {
+	values := s.Spec.Homeserver.Values
+	return values != nil && values.AdminListener != nil && values.AdminListener.Enabled
+}
+
+// adminListenerPort returns the port of the admin listener, defaulting to
+// 8090
+func adminListenerPort(adminListener *synapsev1alpha1.SynapseHomeserverAdminListener) int {
+	if adminListener.Port == 0 {
+		return 8090
+	}
+	return adminListener.Port
+}
+
+// manholePort returns the port of the manhole listener, defaulting to 9010
+func manholePort(manhole *synapsev1alpha1.SynapseHomeserverManhole) int {
+	if manhole.Port == 0 {
+		return 9010
+	}
+	return manhole.Port
+}
+
+// modulesToHomeserver converts a list of SynapseHomeserverModule to the
+// format expected by the modules section of homeserver.yaml.
+func modulesToHomeserver(modules []synapsev1alpha1.SynapseHomeserverModule) ([]map[string]interface{}, error) {
+	homeserverModules := []map[string]interface{}{}
+	for _, module := range modules {
+		config := map[string]interface{}{}
+		if module.Config != nil && len(module.Config.Raw) > 0 {
+			if err := json.Unmarshal(module.Config.Raw, &config); err != nil {
+				return nil, errors.New("invalid config for module " + module.Module + ": " + err.Error())
+			}
+		}
+		homeserverModules = append(homeserverModules, map[string]interface{}{
+			"module": module.Module,
+			"config": config,
+		})
+	}
+	return homeserverModules, nil
+}
+
+// thumbnailSizesToHomeserver converts a list of
+// SynapseHomeserverMediaThumbnailSize to the format expected by the
+// thumbnail_sizes section of homeserver.yaml.
+func thumbnailSizesToHomeserver(sizes []synapsev1alpha1.SynapseHomeserverMediaThumbnailSize) []map[string]interface{} {
+	homeserverSizes := []map[string]interface{}{}
+	for _, size := range sizes {
+		method := size.Method
+		if method == "" {
+			method = "scale"
+		}
+		homeserverSizes = append(homeserverSizes, map[string]interface{}{
+			"width":  size.Width,
+			"height": size.Height,
+			"method": method,
+		})
+	}
+	return homeserverSizes
+}
+
+// emailToHomeserver converts a SynapseHomeserverEmail to the format expected
+// by the email section of homeserver.yaml, without the SMTP credentials.
+// Unset options are omitted, in which case Synapse's defaults apply.
+func emailToHomeserver(email *synapsev1alpha1.SynapseHomeserverEmail) map[string]interface{} {
+	emailConfig := map[string]interface{}{
+		"smtp_host":  email.SMTPHost,
+		"smtp_port":  smtpPort(email),
+		"notif_from": email.NotifFrom,
+	}
+	if email.RequireTransportSecurity {
+		emailConfig["require_transport_security"] = true
+	}
+	if email.AppName != "" {
+		emailConfig["app_name"] = email.AppName
+	}
+	return emailConfig
+}
+
+// smtpPort returns the port of the outgoing SMTP server, defaulting to 25
+func smtpPort(email *synapsev1alpha1.SynapseHomeserverEmail) int {
+	if email.SMTPPort == 0 {
+		return 25
+	}
+	return email.SMTPPort
+}
+
+// oidcToHomeserver converts a SynapseHomeserverOIDC to the format expected by
+// the oidc_config section of homeserver.yaml. Unset options are omitted, in
+// which case Synapse's defaults apply. The client secret is set in the
+// homeserver secrets file by updateHomeserverSecretsForOIDC.
+func oidcToHomeserver(oidc *synapsev1alpha1.SynapseHomeserverOIDC) map[string]interface{} {
+	oidcConfig := map[string]interface{}{
+		"enabled":   true,
+		"issuer":    oidc.Issuer,
+		"client_id": oidc.ClientID,
+	}
+	if len(oidc.Scopes) > 0 {
+		oidcConfig["scopes"] = oidc.Scopes
+	}
+	if oidc.AllowExistingUsers {
+		oidcConfig["allow_existing_users"] = true
+	}
+
+	mappingConfig := map[string]string{}
+	if oidc.SubjectClaim != "" {
+		mappingConfig["subject_claim"] = oidc.SubjectClaim
+	}
+	if oidc.LocalpartTemplate != "" {
+		mappingConfig["localpart_template"] = oidc.LocalpartTemplate
+	}
+	if oidc.DisplayNameTemplate != "" {
+		mappingConfig["display_name_template"] = oidc.DisplayNameTemplate
+	}
+	if len(mappingConfig) > 0 {
+		oidcConfig["user_mapping_provider"] = map[string]interface{}{"config": mappingConfig}
+	}
+
+	return oidcConfig
+}
+
+// oidcProvidersToHomeserver converts a list of SynapseHomeserverOIDCProvider
+// to the format expected by the oidc_providers section of homeserver.yaml.
+func oidcProvidersToHomeserver(providers []synapsev1alpha1.SynapseHomeserverOIDCProvider) []map[string]interface{} {
+	oidcProviders := []map[string]interface{}{}
+	for _, provider := range providers {
+		oidcProvider := oidcToHomeserver(&provider.SynapseHomeserverOIDC)
+		// Providers listed in oidc_providers are always enabled
+		delete(oidcProvider, "enabled")
+		oidcProvider["idp_id"] = provider.IdpID
+		if provider.IdpName != "" {
+			oidcProvider["idp_name"] = provider.IdpName
+		}
+		oidcProviders = append(oidcProviders, oidcProvider)
+	}
+	return oidcProviders
+}
+
+// listenersToHomeserver converts a list of SynapseHomeserverListener to the
+// format expected by the listeners section of homeserver.yaml.
+func listenersToHomeserver(listeners []synapsev1alpha1.SynapseHomeserverListener) []interface{} {
+	homeserverListeners := []interface{}{}
+	for _, listener := range listeners {
+		listenerType := listener.Type
+		if listenerType == "" {
+			listenerType = "http"
+		}
+
+		homeserverListener := map[interface{}]interface{}{
+			"port":        listener.Port,
+			"type":        listenerType,
+			"tls":         false,
+			"x_forwarded": listener.XForwarded,
+		}
+		if len(listener.BindAddresses) > 0 {
+			homeserverListener["bind_addresses"] = listener.BindAddresses
+		}
+
+		if len(listener.Resources) > 0 {
+			resources := []map[string]interface{}{}
+			for _, resource := range listener.Resources {
+				names := []string{}
+				for _, name := range resource.Names {
+					names = append(names, string(name))
+				}
+				resources = append(resources, map[string]interface{}{
+					"names":    names,
+					"compress": resource.Compress,
+				})
+			}
+			homeserverListener["resources"] = resources
+		}
+
+		homeserverListeners = append(homeserverListeners, homeserverListener)
+	}
+
+	return homeserverListeners
+}
+
+// getDefaultListener returns the default HTTP listener of homeserver.yaml,
+// listening on port 8008. The returned map can be modified in place. An
+// error is returned if no such listener exists.
+func getDefaultListener(homeserver map[string]interface{}) (map[interface{}]interface{}, error) {
+	listeners, ok := homeserver["listeners"].([]interface{})
+	if !ok {
+		return nil, errors.New("missing listeners key in homeserver.yaml")
+	}
+
+	for _, l := range listeners {
+		listener, ok := l.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		if listener["port"] == 8008 && listener["type"] == "http" {
+			return listener, nil
+		}
+	}
+
+	return nil, errors.New("missing default HTTP listener on port 8008 in homeserver.yaml")
+}
+
+// directoryRulesToHomeserver converts a list of SynapseHomeserverDirectoryRule
+// to the format expected by the alias_creation_rules and
+// room_list_publication_rules sections of homeserver.yaml. Empty globs are
+// omitted, in which case Synapse defaults them to "*".
+func directoryRulesToHomeserver(rules []synapsev1alpha1.SynapseHomeserverDirectoryRule) []map[string]string {
+	homeserverRules := []map[string]string{}
+	for _, rule := range rules {
+		homeserverRule := map[string]string{"action": rule.Action}
+		if rule.UserID != "" {
+			homeserverRule["user_id"] = rule.UserID
+		}
+		if rule.Alias != "" {
+			homeserverRule["alias"] = rule.Alias
+		}
+		if rule.RoomID != "" {
+			homeserverRule["room_id"] = rule.RoomID
+		}
+		homeserverRules = append(homeserverRules, homeserverRule)
+	}
+	return homeserverRules
+}
+
+// hashConfigMapData returns a SHA-256 hash of the given ConfigMap data
+func hashConfigMapData(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(data[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isRolloutOnChangeEnabled returns true if Synapse should be rolled out when
+// the input ConfigMap is modified. Defaults to true.
+func isRolloutOnChangeEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.Homeserver.ConfigMap.RolloutOnChange == nil || *s.Spec.Homeserver.ConfigMap.RolloutOnChange
 }
 
 // copyInputSynapseConfigMap is a function of type FnWithRequest, to be
@@ -2821,9 +3530,24 @@ func (r *SynapseReconciler) parseInputSynapseConfigMap(ctx context.Context, req
 	}
 
 	if err := r.ParseHomeserverConfigMap(ctx, s, inputConfigMap); err != nil {
+		if err := r.setFailedState(ctx, s, err.Error()); err != nil {
+			log.Error(err, "Error updating Synapse State")
+		}
+
 		return subreconciler.RequeueWithDelayAndError(time.Duration(30), err)
 	}
 
+	// Roll out Synapse if the user modified the input ConfigMap since it was
+	// last copied, so that the new configuration is loaded
+	inputConfigMapHash := hashConfigMapData(inputConfigMap.Data)
+	if s.Status.InputConfigMapHash != "" &&
+		s.Status.InputConfigMapHash != inputConfigMapHash &&
+		isRolloutOnChangeEnabled(s) {
+		log.Info("Input ConfigMap modified, rolling out Synapse", "ConfigMap.Name", ConfigMapName)
+		s.Status.LastForcedReconcile = metav1.Now().Format(time.RFC3339)
+	}
+	s.Status.InputConfigMapHash = inputConfigMapHash
+
 	err, has_patched := r.updateSynapseStatus(ctx, s)
 	if err != nil {
 		log.Error(err, "Error updating Synapse Status")
@@ -2877,6 +3601,11 @@ func (r *SynapseReconciler) ParseHomeserverConfigMap(ctx context.Context, synaps
 		return err
 	}
 
+	if err := utils.HomeserverSchema.Validate("homeserver.yaml", homeserver); err != nil {
+		log.Error(err, "Invalid homeserver.yaml")
+		return err
+	}
+
 	// Populate the Status.HomeserverConfiguration with values defined in homeserver.yaml
 	synapse.Status.HomeserverConfiguration.ServerName = server_name
 	synapse.Status.HomeserverConfiguration.ReportStats = report_stats
@@ -2913,6 +3642,7 @@ func (r *SynapseReconciler) updateSynapseConfigMapForPostgresCluster(ctx context
 		s,
 		r.updateHomeserverWithPostgreSQLInfos,
 		"homeserver.yaml",
+		utils.HomeserverSchema,
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -2965,12 +3695,7 @@ func (r *SynapseReconciler) fetchDatabaseDataFromSynapseStatus(s synapsev1alpha1
 		err := errors.New("missing ConnectionURL in DatabaseConnectionInfo")
 		return map[string]interface{}{}, err
 	}
-	connectionURL := strings.Split(s.Status.DatabaseConnectionInfo.ConnectionURL, ":")
-	if len(connectionURL) < 2 {
-		err := errors.New("error parsing the Connection URL with value: " + s.Status.DatabaseConnectionInfo.ConnectionURL)
-		return map[string]interface{}{}, err
-	}
-	port, err := strconv.ParseInt(connectionURL[1], 10, 64)
+	host, port, err := splitConnectionURL(s.Status.DatabaseConnectionInfo.ConnectionURL)
 	if err != nil {
 		return map[string]interface{}{}, err
 	}
@@ -2980,7 +3705,7 @@ func (r *SynapseReconciler) fetchDatabaseDataFromSynapseStatus(s synapsev1alpha1
 	databaseData.Args.User = s.Status.DatabaseConnectionInfo.User
 	databaseData.Args.Password = decodedPassword
 	databaseData.Args.Database = s.Status.DatabaseConnectionInfo.DatabaseName
-	databaseData.Args.Host = connectionURL[0]
+	databaseData.Args.Host = host
 	databaseData.Args.Port = port
 	databaseData.Args.CpMin = 5
 	databaseData.Args.CpMax = 10
@@ -2994,6 +3719,133 @@ func (r *SynapseReconciler) fetchDatabaseDataFromSynapseStatus(s synapsev1alpha1
 	return databaseDataMap, nil
 }
 
+// splitConnectionURL splits the host:port Connection URL of the database into
+// its host and port. IPv6 hosts are bracketed, except in the Connection URLs
+// written by earlier versions of the operator, in which case the port follows
+// the last colon.
+func splitConnectionURL(connectionURL string) (string, int64, error) {
+	host, portString, err := net.SplitHostPort(connectionURL)
+	if err != nil {
+		i := strings.LastIndex(connectionURL, ":")
+		if i < 0 || net.ParseIP(connectionURL[:i]) == nil {
+			return "", 0, errors.New("error parsing the Connection URL with value: " + connectionURL)
+		}
+		host, portString = connectionURL[:i], connectionURL[i+1:]
+	}
+
+	port, err := strconv.ParseInt(portString, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return host, port, nil
+}
+
+// updateSynapseConfigMapForMediaStore is a function of type FnWithRequest,
+// to be called in the main reconciliation loop.
+//
+// It configures the 'media_store_path' of homeserver.yaml to point at the
+// dedicated media store PVC.
+func (r *SynapseReconciler) updateSynapseConfigMapForMediaStore(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithMediaStoreInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithMediaStoreInfos is a function of type updateDataFunc
+// function to be passed as an argument in a call to utils.UpdateConfigMap.
+//
+// It sets the media_store_path to the mount path of the media store PVC.
+func (r *SynapseReconciler) updateHomeserverWithMediaStoreInfos(
+	_ client.Object,
+	homeserver map[string]interface{},
+) error {
+	homeserver["media_store_path"] = synapseMediaStorePath
+	return nil
+}
+
+// updateSynapseConfigMapForMetrics is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It enables the collection of metrics in homeserver.yaml and adds a metrics
+// listener.
+func (r *SynapseReconciler) updateSynapseConfigMapForMetrics(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithMetricsInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithMetricsInfos is a function of type updateDataFunc
+// function to be passed as an argument in a call to utils.UpdateConfigMap.
+//
+// It sets enable_metrics and adds a metrics listener, unless a listener
+// already exists on the metrics port.
+func (r *SynapseReconciler) updateHomeserverWithMetricsInfos(
+	_ client.Object,
+	homeserver map[string]interface{},
+) error {
+	homeserver["enable_metrics"] = true
+
+	listeners, _ := homeserver["listeners"].([]interface{})
+	for _, l := range listeners {
+		if listener, ok := l.(map[interface{}]interface{}); ok && listener["port"] == synapseMetricsPort {
+			return nil
+		}
+	}
+
+	homeserver["listeners"] = append(listeners, map[string]interface{}{
+		"port":           synapseMetricsPort,
+		"type":           "metrics",
+		"bind_addresses": []string{"0.0.0.0"},
+	})
+	return nil
+}
+
+// isMetricsEnabled returns true if Spec.Metrics is enabled
+func isMetricsEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.Metrics != nil && s.Spec.Metrics.Enabled
+}
+
 // updateSynapseConfigMapForHeisenbridge is a function of type
 // FnWithRequest, to be called in the main reconciliation loop.
 //
@@ -3018,6 +3870,7 @@ func (r *SynapseReconciler) updateSynapseConfigMapForHeisenbridge(ctx context.Co
 		s,
 		r.updateHomeserverWithHeisenbridgeInfos,
 		"homeserver.yaml",
+		utils.HomeserverSchema,
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -3034,7 +3887,7 @@ func (r *SynapseReconciler) updateHomeserverWithHeisenbridgeInfos(
 	homeserver map[string]interface{},
 ) error {
 	// Add heisenbridge configuration file to the list of application services
-	r.addAppServiceToHomeserver(homeserver, "/data-heisenbridge/heisenbridge.yaml")
+	r.addAppServiceToHomeserver(homeserver, utils.HeisenbridgeConfigPath)
 	return nil
 }
 
@@ -3062,6 +3915,7 @@ func (r *SynapseReconciler) updateSynapseConfigMapForMautrixSignal(ctx context.C
 		s,
 		r.updateHomeserverWithMautrixSignalInfos,
 		"homeserver.yaml",
+		utils.HomeserverSchema,
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -3082,6 +3936,76 @@ func (r *SynapseReconciler) updateHomeserverWithMautrixSignalInfos(
 	return nil
 }
 
+// removeMautrixSignalFromSynapseConfigMap is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It removes the mautrix-signal bridge from the application services
+// registered in the homeserver.yaml config file.
+func (r *SynapseReconciler) removeMautrixSignalFromSynapseConfigMap(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithoutMautrixSignalInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithoutMautrixSignalInfos is a function of type
+// updateDataFunc function to be passed as an argument in a call to
+// utils.UpdateConfigMap.
+//
+// It removes the mautrix-signal registration file from the list of
+// application services, if present.
+func (r *SynapseReconciler) updateHomeserverWithoutMautrixSignalInfos(
+	_ client.Object,
+	homeserver map[string]interface{},
+) error {
+	r.removeAppServiceFromHomeserver(homeserver, "/data-mautrixsignal/registration.yaml")
+	return nil
+}
+
+func (r *SynapseReconciler) removeAppServiceFromHomeserver(
+	homeserver map[string]interface{},
+	configFilePath string,
+) {
+	homeserverAppService, ok := homeserver["app_service_config_files"].([]interface{})
+	if !ok {
+		// "app_service_config_files" key not present, or malformed. Nothing
+		// to remove.
+		return
+	}
+
+	appServices := []interface{}{}
+	for _, appService := range homeserverAppService {
+		if appService != configFilePath {
+			appServices = append(appServices, appService)
+		}
+	}
+
+	if len(appServices) == 0 {
+		delete(homeserver, "app_service_config_files")
+	} else {
+		homeserver["app_service_config_files"] = appServices
+	}
+}
+
 func (r *SynapseReconciler) addAppServiceToHomeserver(
 	homeserver map[string]interface{},
 	configFilePath string,
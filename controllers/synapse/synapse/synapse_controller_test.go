@@ -225,6 +225,55 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 							},
 						}},
 				}),
+				Entry("when Synapse spec Homeserver Values has an alias creation rule with an invalid action", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"homeserver": map[string]interface{}{
+							"values": map[string]interface{}{
+								"serverName":  ServerName,
+								"reportStats": ReportStats,
+								"aliasCreationRules": []interface{}{
+									map[string]interface{}{"userID": "*", "action": "maybe"},
+								},
+							},
+						}},
+				}),
+				Entry("when Synapse spec Homeserver Values has an unknown default room version", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"homeserver": map[string]interface{}{
+							"values": map[string]interface{}{
+								"serverName":         ServerName,
+								"reportStats":        ReportStats,
+								"defaultRoomVersion": "11",
+							},
+						}},
+				}),
+				Entry("when Synapse spec Homeserver Values has an OIDC configuration with an empty localpart template", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"homeserver": map[string]interface{}{
+							"values": map[string]interface{}{
+								"serverName":  ServerName,
+								"reportStats": ReportStats,
+								"oidc": map[string]interface{}{
+									"issuer":            "https://accounts.example.com/",
+									"clientID":          "synapse",
+									"localpartTemplate": "",
+								},
+							},
+						}},
+				}),
+				Entry("when Synapse spec Homeserver Values has OIDC providers with duplicated IDs", map[string]interface{}{
+					"spec": map[string]interface{}{
+						"homeserver": map[string]interface{}{
+							"values": map[string]interface{}{
+								"serverName":  ServerName,
+								"reportStats": ReportStats,
+								"oidcProviders": []interface{}{
+									map[string]interface{}{"idpID": "sso", "issuer": "https://a.example.com/", "clientID": "a"},
+									map[string]interface{}{"idpID": "sso", "issuer": "https://b.example.com/", "clientID": "b"},
+								},
+							},
+						}},
+				}),
 				// This should not work but passes
 				PEntry("when Synapse spec possesses an invalid field", map[string]interface{}{
 					"spec": map[string]interface{}{
@@ -394,12 +443,29 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 
 				It("Should should update the Synapse Status", func() {
 					expectedStatus := synapsev1alpha1.SynapseStatus{
-						State:  "RUNNING",
-						Reason: "",
+						State:         "RUNNING",
+						Reason:        "",
+						ServerName:    ServerName,
+						ClientBaseURL: "http://" + SynapseName + "." + SynapseNamespace + ".svc.cluster.local:8008",
 						HomeserverConfiguration: synapsev1alpha1.SynapseStatusHomeserverConfiguration{
 							ServerName:  ServerName,
 							ReportStats: ReportStats,
 						},
+						RegistrationSharedSecretRef: &synapsev1alpha1.SynapseStatusSecretKeyRef{
+							Name: SynapseName + "-registration",
+							Key:  "registration_shared_secret",
+						},
+						ManagedResources: []synapsev1alpha1.SynapseStatusManagedResource{
+							{Kind: "ConfigMap", Name: SynapseName},
+							{Kind: "Secret", Name: SynapseName + "-homeserver-secrets"},
+							{Kind: "Secret", Name: SynapseName + "-registration"},
+							{Kind: "Secret", Name: SynapseName + "-macaroon"},
+							{Kind: "ServiceAccount", Name: SynapseName},
+							{Kind: "RoleBinding", Name: SynapseName},
+							{Kind: "Service", Name: SynapseName},
+							{Kind: "PersistentVolumeClaim", Name: SynapseName},
+							{Kind: "Deployment", Name: SynapseName},
+						},
 					}
 					// Status may need some time to be updated
 					Eventually(func() synapsev1alpha1.SynapseStatus {
@@ -408,6 +474,67 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 					}, timeout, interval).Should(Equal(expectedStatus))
 				})
 
+				It("Should create a Secret holding the registration shared secret", func() {
+					registrationSecret := &corev1.Secret{}
+					registrationSecretLookupKey := types.NamespacedName{
+						Name:      SynapseName + "-registration",
+						Namespace: SynapseNamespace,
+					}
+					checkResourcePresence(registrationSecret, registrationSecretLookupKey, expectedOwnerReference)
+					Expect(registrationSecret.Data).Should(HaveKey("registration_shared_secret"))
+				})
+
+				It("Should not create a worker replication secret without workers", func() {
+					replicationSecret := &corev1.Secret{}
+					replicationSecretLookupKey := types.NamespacedName{
+						Name:      SynapseName + "-replication",
+						Namespace: SynapseNamespace,
+					}
+					Consistently(func() error {
+						return k8sClient.Get(ctx, replicationSecretLookupKey, replicationSecret)
+					}, duration, interval).ShouldNot(Succeed())
+				})
+
+				It("Should create a Secret holding the macaroon secret key", func() {
+					macaroonSecret := &corev1.Secret{}
+					macaroonSecretLookupKey := types.NamespacedName{
+						Name:      SynapseName + "-macaroon",
+						Namespace: SynapseNamespace,
+					}
+					checkResourcePresence(macaroonSecret, macaroonSecretLookupKey, expectedOwnerReference)
+					Expect(macaroonSecret.Data).Should(HaveKey("macaroon_secret_key"))
+					macaroonSecretKey := string(macaroonSecret.Data["macaroon_secret_key"])
+
+					By("Checking that the key is configured in the homeserver secrets file")
+					homeserverSecrets := &corev1.Secret{}
+					homeserverSecretsLookupKey := types.NamespacedName{
+						Name:      SynapseName + "-homeserver-secrets",
+						Namespace: SynapseNamespace,
+					}
+					Eventually(func() interface{} {
+						_ = k8sClient.Get(ctx, homeserverSecretsLookupKey, homeserverSecrets)
+						secrets, _ := utils.LoadYAMLFileFromSecretData(*homeserverSecrets, "secrets.yaml")
+						return secrets["macaroon_secret_key"]
+					}, timeout, interval).Should(Equal(macaroonSecretKey))
+
+					By("Checking that the key is not embedded in homeserver.yaml")
+					Expect(k8sClient.Get(ctx, synapseLookupKey, createdConfigMap)).Should(Succeed())
+					homeserver, _ := utils.LoadYAMLFileFromConfigMapData(*createdConfigMap, "homeserver.yaml")
+					Expect(homeserver).ShouldNot(HaveKey("macaroon_secret_key"))
+
+					By("Forcing a new reconciliation")
+					Expect(k8sClient.Get(ctx, synapseLookupKey, synapse)).Should(Succeed())
+					patch := client.MergeFrom(synapse.DeepCopy())
+					synapse.SetAnnotations(map[string]string{synapsev1alpha1.ForceReconcileAnnotation: ""})
+					Expect(k8sClient.Patch(ctx, synapse, patch)).Should(Succeed())
+
+					By("Checking that the key is stable across reconciliations")
+					Consistently(func() string {
+						_ = k8sClient.Get(ctx, macaroonSecretLookupKey, macaroonSecret)
+						return string(macaroonSecret.Data["macaroon_secret_key"])
+					}, timeout, interval).Should(Equal(macaroonSecretKey))
+				})
+
 				It("Should create a Synapse ConfigMap", func() {
 					checkResourcePresence(createdConfigMap, synapseLookupKey, expectedOwnerReference)
 				})
@@ -429,6 +556,11 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 						Value: utils.BoolToYesNo(ReportStats),
 					}}
 					Expect(createdDeployment.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElements(envVars))
+
+					By("Checking that the probes query the default health endpoint")
+					Expect(createdDeployment.Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet.Path).Should(Equal("/health"))
+					Expect(createdDeployment.Spec.Template.Spec.Containers[0].LivenessProbe.HTTPGet.Path).Should(Equal("/health"))
+					Expect(createdDeployment.Spec.Template.Spec.Containers[0].StartupProbe.HTTPGet.Path).Should(Equal("/health"))
 				})
 
 				It("Should create a Synapse Service", func() {
@@ -442,6 +574,54 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 				It("Should create a Synapse RoleBinding", func() {
 					checkResourcePresence(createdRoleBinding, synapseLookupKey, expectedOwnerReference)
 				})
+
+				It("Should process a forced reconciliation request", func() {
+					By("Annotating the Synapse instance")
+					Expect(k8sClient.Get(ctx, synapseLookupKey, synapse)).Should(Succeed())
+					patch := client.MergeFrom(synapse.DeepCopy())
+					synapse.SetAnnotations(map[string]string{synapsev1alpha1.ForceReconcileAnnotation: ""})
+					Expect(k8sClient.Patch(ctx, synapse, patch)).Should(Succeed())
+
+					By("Checking that the annotation is removed and the request recorded")
+					Eventually(func() bool {
+						_ = k8sClient.Get(ctx, synapseLookupKey, synapse)
+						_, annotated := synapse.Annotations[synapsev1alpha1.ForceReconcileAnnotation]
+						return !annotated &&
+							synapse.Status.LastForcedReconcile != "" &&
+							!synapse.Status.NeedsReconcile &&
+							synapse.Status.State == "RUNNING"
+					}, timeout, interval).Should(BeTrue())
+
+					By("Checking that the Synapse Deployment Pod template has been updated")
+					Eventually(func() string {
+						_ = k8sClient.Get(ctx, synapseLookupKey, createdDeployment)
+						return createdDeployment.Spec.Template.Annotations["synapse.opdev.io/restartedAt"]
+					}, timeout, interval).Should(Equal(synapse.Status.LastForcedReconcile))
+				})
+
+				It("Should remove the scheduling constraints cleared from the Spec", func() {
+					By("Setting a node selector")
+					Expect(k8sClient.Get(ctx, synapseLookupKey, synapse)).Should(Succeed())
+					patch := client.MergeFrom(synapse.DeepCopy())
+					synapse.Spec.NodeSelector = map[string]string{"disktype": "ssd"}
+					Expect(k8sClient.Patch(ctx, synapse, patch)).Should(Succeed())
+
+					Eventually(func() map[string]string {
+						_ = k8sClient.Get(ctx, synapseLookupKey, createdDeployment)
+						return createdDeployment.Spec.Template.Spec.NodeSelector
+					}, timeout, interval).Should(HaveKeyWithValue("disktype", "ssd"))
+
+					By("Clearing the node selector")
+					Expect(k8sClient.Get(ctx, synapseLookupKey, synapse)).Should(Succeed())
+					patch = client.MergeFrom(synapse.DeepCopy())
+					synapse.Spec.NodeSelector = nil
+					Expect(k8sClient.Patch(ctx, synapse, patch)).Should(Succeed())
+
+					Eventually(func() map[string]string {
+						_ = k8sClient.Get(ctx, synapseLookupKey, createdDeployment)
+						return createdDeployment.Spec.Template.Spec.NodeSelector
+					}, timeout, interval).Should(BeEmpty())
+				})
 			})
 
 			When("Specifying the Synapse configuration via a ConfigMap", func() {
@@ -495,12 +675,23 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 
 					It("Should should update the Synapse Status", func() {
 						expectedStatus := synapsev1alpha1.SynapseStatus{
-							State:  "RUNNING",
-							Reason: "",
+							State:         "RUNNING",
+							Reason:        "",
+							ServerName:    ServerName,
+							ClientBaseURL: "http://" + SynapseName + "." + SynapseNamespace + ".svc.cluster.local:8008",
 							HomeserverConfiguration: synapsev1alpha1.SynapseStatusHomeserverConfiguration{
 								ServerName:  ServerName,
 								ReportStats: ReportStats,
 							},
+							InputConfigMapHash: hashConfigMapData(inputConfigmapData),
+							ManagedResources: []synapsev1alpha1.SynapseStatusManagedResource{
+								{Kind: "ConfigMap", Name: SynapseName},
+								{Kind: "ServiceAccount", Name: SynapseName},
+								{Kind: "RoleBinding", Name: SynapseName},
+								{Kind: "Service", Name: SynapseName},
+								{Kind: "PersistentVolumeClaim", Name: SynapseName},
+								{Kind: "Deployment", Name: SynapseName},
+							},
 						}
 						// Status may need some time to be updated
 						Eventually(func() synapsev1alpha1.SynapseStatus {
@@ -543,6 +734,30 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 					It("Should create a Synapse RoleBinding", func() {
 						checkResourcePresence(createdRoleBinding, synapseLookupKey, expectedOwnerReference)
 					})
+
+					It("Should roll out Synapse when the input ConfigMap is modified", func() {
+						By("Modifying the input ConfigMap")
+						Expect(k8sClient.Get(ctx, types.NamespacedName{
+							Name:      InputConfigMapName,
+							Namespace: SynapseNamespace,
+						}, inputConfigMap)).Should(Succeed())
+						inputConfigMap.Data["homeserver.yaml"] += "\nenable_registration: false"
+						Expect(k8sClient.Update(ctx, inputConfigMap)).Should(Succeed())
+
+						By("Checking that the modification is copied")
+						Eventually(func() string {
+							_ = k8sClient.Get(ctx, synapseLookupKey, createdConfigMap)
+							return createdConfigMap.Data["homeserver.yaml"]
+						}, timeout, interval).Should(ContainSubstring("enable_registration"))
+
+						By("Checking that the Synapse Deployment Pod template has been updated")
+						Eventually(func() bool {
+							_ = k8sClient.Get(ctx, synapseLookupKey, synapse)
+							_ = k8sClient.Get(ctx, synapseLookupKey, createdDeployment)
+							return synapse.Status.LastForcedReconcile != "" &&
+								createdDeployment.Spec.Template.Annotations["synapse.opdev.io/restartedAt"] == synapse.Status.LastForcedReconcile
+						}, timeout, interval).Should(BeTrue())
+					})
 				})
 
 				When("Requesting a new PostgreSQL instance to be created for Synapse", func() {
@@ -980,6 +1195,8 @@ var _ = Describe("Integration tests for the Synapse controller", Ordered, Label(
 			testEnv = &envtest.Environment{
 				CRDDirectoryPaths: []string{
 					filepath.Join("..", "..", "..", "bundle", "manifests", "synapse.opdev.io_synapses.yaml"),
+					filepath.Join("..", "..", "..", "bundle", "manifests", "synapse.opdev.io_heisenbridges.yaml"),
+					filepath.Join("..", "..", "..", "bundle", "manifests", "synapse.opdev.io_mautrixsignals.yaml"),
 				},
 				ErrorIfCRDPathMissing: true,
 			}
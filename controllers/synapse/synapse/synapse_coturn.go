@@ -0,0 +1,481 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Port on which the coturn server listens, for both UDP and TCP
+const coturnListeningPort = 3478
+
+// Range of the UDP ports on which coturn allocates the relays. It is kept
+// small, as each port is exposed on the coturn Service.
+const (
+	coturnMinRelayPort = 49160
+	coturnMaxRelayPort = 49169
+)
+
+// Key of the coturn Secret holding the shared secret
+const coturnSharedSecretKey = "shared-secret"
+
+func GetCoturnResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "coturn"}, "-")
+}
+
+// isCoturnEnabled returns true if a coturn TURN server is deployed alongside
+// Synapse
+func isCoturnEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.TURN != nil && s.Spec.TURN.Deploy
+}
+
+// labelsForCoturn returns the labels for selecting the coturn resources
+// belonging to the given synapse CR name.
+func labelsForCoturn(name string) map[string]string {
+	return map[string]string{"app": "coturn", "synapse_cr": name}
+}
+
+// reconcileCoturnSecret is a function of type FnWithRequest, to be called in
+// the main reconciliation loop.
+//
+// It creates the Secret holding the shared secret used by Synapse to
+// generate credentials for the coturn server.
+func (r *SynapseReconciler) reconcileCoturnSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.reconcileGeneratedSecret(ctx, req, coturnSharedSecret)
+}
+
+// reconcileCoturnDeployment is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the Deployment for coturn to its desired state. With a
+// LoadBalancer Service, coturn advertises the address of the LoadBalancer
+// in its relay allocations, so the Deployment waits for it to be assigned:
+// the update of the Service Status triggers a new reconciliation.
+func (r *SynapseReconciler) reconcileCoturnDeployment(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	externalIP := ""
+	if coturnServiceType(s) == corev1.ServiceTypeLoadBalancer {
+		var coturnService corev1.Service
+		keyForCoturnService := types.NamespacedName{
+			Name:      GetCoturnResourceName(*s),
+			Namespace: s.Namespace,
+		}
+		if err := r.Get(ctx, keyForCoturnService, &coturnService); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+
+		var err error
+		externalIP, err = coturnExternalIP(ctx, s, coturnService)
+		if err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		if externalIP == "" {
+			log.Info("Waiting for the coturn LoadBalancer address", "Service.Name", coturnService.Name)
+			return subreconciler.ContinueReconciling()
+		}
+	}
+
+	objectMetaForCoturn := reconcile.SetObjectMeta(GetCoturnResourceName(*s), s.Namespace, map[string]string{})
+	depl, err := r.deploymentForCoturn(s, objectMetaForCoturn, externalIP)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		depl,
+		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentStrategy(depl),
+		func(current client.Object) {
+			current.(*appsv1.Deployment).Spec.Template.Spec.HostNetwork = depl.Spec.Template.Spec.HostNetwork
+		},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// coturnExternalIP returns the public IP address advertised by coturn in
+// its relay allocations: Spec.TURN.Host if it is an IP address, or the
+// address assigned to the LoadBalancer, resolved if it is a host name. It
+// returns an empty string if the LoadBalancer address isn't known yet.
+func coturnExternalIP(ctx context.Context, s *synapsev1alpha1.Synapse, coturnService corev1.Service) (string, error) {
+	if ip := net.ParseIP(s.Spec.TURN.Host); ip != nil {
+		return ip.String(), nil
+	}
+
+	for _, ingress := range coturnService.Status.LoadBalancer.Ingress {
+		if ip := net.ParseIP(ingress.IP); ip != nil {
+			return ip.String(), nil
+		}
+		if ingress.Hostname != "" {
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, ingress.Hostname)
+			if err != nil {
+				return "", err
+			}
+			if len(addrs) > 0 {
+				return addrs[0].IP.String(), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// deploymentForCoturn returns a coturn Deployment object. With a
+// LoadBalancer Service, coturn advertises the given external IP address in
+// its relay allocations, on the relay ports exposed on the Service. With a
+// NodePort Service, coturn runs on the host network, so that the relay
+// ports are bound on the node and coturn advertises the address of the
+// node.
+func (r *SynapseReconciler) deploymentForCoturn(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta, externalIP string) (*appsv1.Deployment, error) {
+	ls := labelsForCoturn(s.Name)
+	replicas := int32(1)
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Image: s.Spec.TURN.Image,
+						Name:  "coturn",
+						Args: []string{
+							"-n",
+							"--log-file=stdout",
+							"--no-cli",
+							"--no-tls",
+							"--no-dtls",
+							"--listening-port=" + strconv.Itoa(coturnListeningPort),
+							"--min-port=" + strconv.Itoa(coturnMinRelayPort),
+							"--max-port=" + strconv.Itoa(coturnMaxRelayPort),
+							"--realm=" + s.Status.HomeserverConfiguration.ServerName,
+							"--use-auth-secret",
+							"--static-auth-secret=$(TURN_SHARED_SECRET)",
+						},
+						Env: []corev1.EnvVar{{
+							Name: "TURN_SHARED_SECRET",
+							ValueFrom: &corev1.EnvVarSource{
+								SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: objectMeta.Name,
+									},
+									Key: coturnSharedSecretKey,
+								},
+							},
+						}},
+						Ports: []corev1.ContainerPort{{
+							Name:          "turn-udp",
+							ContainerPort: coturnListeningPort,
+							Protocol:      corev1.ProtocolUDP,
+						}, {
+							Name:          "turn-tcp",
+							ContainerPort: coturnListeningPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	if externalIP != "" {
+		dep.Spec.Template.Spec.Containers[0].Args = append(
+			dep.Spec.Template.Spec.Containers[0].Args,
+			"--external-ip="+externalIP,
+		)
+	}
+
+	if coturnServiceType(s) == corev1.ServiceTypeNodePort {
+		dep.Spec.Template.Spec.HostNetwork = true
+		// The new pod can't bind the ports of the node while the old one runs
+		dep.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, dep, r.Scheme); err != nil {
+		return &appsv1.Deployment{}, err
+	}
+
+	return dep, nil
+}
+
+// reconcileCoturnService is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It reconciles the Service for coturn to its desired state.
+func (r *SynapseReconciler) reconcileCoturnService(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForCoturn := reconcile.SetObjectMeta(GetCoturnResourceName(*s), s.Namespace, map[string]string{})
+	desiredService, err := r.serviceForCoturn(s, objectMetaForCoturn)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredService,
+		&corev1.Service{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// serviceForCoturn returns a coturn Service object
+func (r *SynapseReconciler) serviceForCoturn(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: objectMeta,
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       "turn-udp",
+				Protocol:   corev1.ProtocolUDP,
+				Port:       coturnListeningPort,
+				TargetPort: intstr.FromInt(coturnListeningPort),
+			}, {
+				Name:       "turn-tcp",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       coturnListeningPort,
+				TargetPort: intstr.FromInt(coturnListeningPort),
+			}},
+			Selector: labelsForCoturn(s.Name),
+			Type:     coturnServiceType(s),
+		},
+	}
+
+	if coturnServiceType(s) == corev1.ServiceTypeLoadBalancer {
+		for port := coturnMinRelayPort; port <= coturnMaxRelayPort; port++ {
+			service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+				Name:       "relay-" + strconv.Itoa(port),
+				Protocol:   corev1.ProtocolUDP,
+				Port:       int32(port),
+				TargetPort: intstr.FromInt(port),
+			})
+		}
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
+		return &corev1.Service{}, err
+	}
+
+	return service, nil
+}
+
+// updateSynapseConfigMapForCoturn is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It configures the 'turn_uris' of homeserver.yaml to point to the public
+// address of the coturn Service. While the LoadBalancer address is not
+// assigned yet, the URIs are left out: the update of the Service Status
+// triggers a new reconciliation. The shared secret is configured in the
+// homeserver secrets file by updateHomeserverSecretsForTURN.
+func (r *SynapseReconciler) updateSynapseConfigMapForCoturn(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var coturnService corev1.Service
+	keyForCoturnService := types.NamespacedName{
+		Name:      GetCoturnResourceName(*s),
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForCoturnService, &coturnService); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	uris := coturnURIs(s, coturnService)
+	if len(uris) == 0 {
+		log.Info("Waiting for the coturn LoadBalancer address", "Service.Name", coturnService.Name)
+		return subreconciler.ContinueReconciling()
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithCoturnInfos(obj, homeserver, uris)
+		},
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithCoturnInfos configures homeserver.yaml to use the
+// coturn server reachable on the given URIs
+func (r *SynapseReconciler) updateHomeserverWithCoturnInfos(
+	_ client.Object,
+	homeserver map[string]interface{},
+	uris []string,
+) error {
+	homeserver["turn_uris"] = uris
+	return nil
+}
+
+// coturnServiceType returns the type of the coturn Service, defaulting to
+// LoadBalancer
+func coturnServiceType(s *synapsev1alpha1.Synapse) corev1.ServiceType {
+	if s.Spec.TURN.ServiceType == "" {
+		return corev1.ServiceTypeLoadBalancer
+	}
+	return s.Spec.TURN.ServiceType
+}
+
+// coturnURIs returns the TURN URIs on which the clients reach the coturn
+// server, given the coturn Service. The host is Spec.TURN.Host, or the
+// address assigned to the LoadBalancer. With a NodePort Service, the node
+// ports allocated to the TURN ports are used. It returns nil if the public
+// address isn't known yet.
+func coturnURIs(s *synapsev1alpha1.Synapse, coturnService corev1.Service) []string {
+	host := s.Spec.TURN.Host
+	if host == "" && coturnServiceType(s) == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range coturnService.Status.LoadBalancer.Ingress {
+			if ingress.Hostname != "" {
+				host = ingress.Hostname
+			} else {
+				host = ingress.IP
+			}
+			if host != "" {
+				break
+			}
+		}
+	}
+	if host == "" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	uris := []string{}
+	for _, port := range coturnService.Spec.Ports {
+		// Only the listening ports are used by the clients to reach coturn
+		if !strings.HasPrefix(port.Name, "turn-") {
+			continue
+		}
+
+		portNumber := port.Port
+		if coturnServiceType(s) == corev1.ServiceTypeNodePort {
+			if port.NodePort == 0 {
+				return nil
+			}
+			portNumber = port.NodePort
+		}
+		uris = append(uris, "turn:"+host+":"+strconv.Itoa(int(portNumber))+"?transport="+strings.ToLower(string(port.Protocol)))
+	}
+
+	return uris
+}
+
+// cleanupCoturn is a function of type FnWithRequest, to be called in the main
+// reconciliation loop.
+//
+// When coturn is not deployed, it deletes the coturn Deployment, Service and
+// Secret left over from a previous configuration, if any. The 'turn_uris' go
+// away with the regenerated homeserver.yaml, and the shared secret is removed
+// from the homeserver secrets file by updateHomeserverSecretsForTURN.
+func (r *SynapseReconciler) cleanupCoturn(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForCoturn := types.NamespacedName{
+		Name:      GetCoturnResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.Secret{}} {
+		if err := r.Get(ctx, keyForCoturn, obj); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return subreconciler.RequeueWithError(err)
+		}
+
+		// Only delete resources managed by this Synapse instance
+		if !metav1.IsControlledBy(obj, s) {
+			continue
+		}
+
+		log.Info("Deleting the managed coturn resource", "Name", keyForCoturn.Name)
+		if err := r.Delete(ctx, obj); err != nil && !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	return subreconciler.ContinueReconciling()
+}
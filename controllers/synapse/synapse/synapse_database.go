@@ -0,0 +1,176 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+func GetDatabaseServiceResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "database"}, "-")
+}
+
+// isExternalDatabaseEnabled returns true if Synapse connects to an existing
+// PostgreSQL database, described by Spec.Database.ExternalSecret
+func isExternalDatabaseEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.Database != nil && s.Spec.Database.ExternalSecret != ""
+}
+
+// getExternalDatabaseSecret returns the Secret holding the connection
+// information of the external database
+func (r *SynapseReconciler) getExternalDatabaseSecret(ctx context.Context, s *synapsev1alpha1.Synapse) (corev1.Secret, error) {
+	var secret corev1.Secret
+	keyForSecret := types.NamespacedName{
+		Name:      s.Spec.Database.ExternalSecret,
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForSecret, &secret); err != nil {
+		return corev1.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// reconcileDatabaseExternalNameService is a function of type FnWithRequest,
+// to be called in the main reconciliation loop.
+//
+// It reconciles the ExternalName Service pointing at the host of the
+// external database to its desired state.
+func (r *SynapseReconciler) reconcileDatabaseExternalNameService(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	secret, err := r.getExternalDatabaseSecret(ctx, s)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	host, ok := secret.Data["host"]
+	if !ok {
+		return subreconciler.RequeueWithError(errors.New("missing host in PostgreSQL Secret " + secret.Name))
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetDatabaseServiceResourceName(*s), s.Namespace, map[string]string{})
+	desiredService, err := r.databaseServiceForSynapse(s, objectMeta, string(host))
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredService,
+		&corev1.Service{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// databaseServiceForSynapse returns an ExternalName Service object pointing
+// at the given database host
+func (r *SynapseReconciler) databaseServiceForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta, host string) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: objectMeta,
+		Spec: corev1.ServiceSpec{
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: host,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
+		return &corev1.Service{}, err
+	}
+	return service, nil
+}
+
+// updateSynapseStatusWithExternalDatabaseInfos is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It updates the Synapse Status with the connection information of the
+// external database. If the ExternalName Service is enabled, Synapse
+// connects to the database through the Service.
+func (r *SynapseReconciler) updateSynapseStatusWithExternalDatabaseInfos(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	secret, err := r.getExternalDatabaseSecret(ctx, s)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := r.updateSynapseStatusExternalDatabase(s, secret); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	err, has_patched := r.updateSynapseStatus(ctx, s)
+	if err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+	if has_patched {
+		return subreconciler.Requeue()
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateSynapseStatusExternalDatabase locally updates the Synapse Status
+// with the connection information held by the external database Secret.
+func (r *SynapseReconciler) updateSynapseStatusExternalDatabase(s *synapsev1alpha1.Synapse, secret corev1.Secret) error {
+	if err := r.updateSynapseStatusDatabase(s, secret); err != nil {
+		return err
+	}
+
+	// Already validated by updateSynapseStatusDatabase
+	connectionInfo, _ := parsePostgresSecret(secret.Data)
+
+	// Unlike the database created with CreateNewPostgreSQL, the name of an
+	// external database is not known in advance
+	s.Status.DatabaseConnectionInfo.DatabaseName = connectionInfo["dbname"]
+
+	if s.Spec.Database.ExternalNameService {
+		s.Status.DatabaseConnectionInfo.ConnectionURL = net.JoinHostPort(
+			utils.ComputeFQDN(GetDatabaseServiceResourceName(*s), s.Namespace),
+			connectionInfo["port"],
+		)
+	}
+
+	return nil
+}
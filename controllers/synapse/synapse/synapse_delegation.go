@@ -0,0 +1,360 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Port on which nginx serves the well-known files
+const wellKnownPort = 8080
+
+// nginx configuration serving the well-known files as JSON. The client file
+// is fetched by web clients, and must be allowed by CORS. Remote servers and
+// clients cache the files for an hour.
+const wellKnownNginxConfig = `server {
+    listen 8080;
+
+    location /.well-known/matrix/ {
+        root /usr/share/nginx/html;
+        default_type application/json;
+        add_header Access-Control-Allow-Origin *;
+        add_header Cache-Control "public, max-age=3600";
+    }
+}
+`
+
+func GetWellKnownResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "well", "known"}, "-")
+}
+
+// labelsForWellKnown returns the labels for selecting the well-known
+// resources belonging to the given synapse CR name.
+func labelsForWellKnown(name string) map[string]string {
+	return map[string]string{"app": "synapse-well-known", "synapse_cr": name}
+}
+
+// isWellKnownDelegationEnabled returns true if the operator serves the
+// well-known delegation files for the server name
+func isWellKnownDelegationEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.WellKnown != nil && s.Spec.WellKnown.Enabled
+}
+
+// reconcileWellKnownConfigMap is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the ConfigMap holding the well-known files and the nginx
+// configuration to its desired state.
+func (r *SynapseReconciler) reconcileWellKnownConfigMap(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForWellKnown := reconcile.SetObjectMeta(GetWellKnownResourceName(*s), s.Namespace, map[string]string{})
+	desiredConfigMap, err := r.configMapForWellKnown(s, objectMetaForWellKnown)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredConfigMap,
+		&corev1.ConfigMap{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// wellKnownDelegatedServer returns the server name and port advertised in
+// the m.server field of the well-known server file. It defaults to port 443
+// of the server name found in the Synapse Status.
+func wellKnownDelegatedServer(s *synapsev1alpha1.Synapse) string {
+	if s.Spec.WellKnown.Server != "" {
+		return s.Spec.WellKnown.Server
+	}
+	return s.Status.HomeserverConfiguration.ServerName + ":443"
+}
+
+// configMapForWellKnown returns a ConfigMap object holding the server and
+// client well-known files, and the nginx configuration serving them. The
+// default delegation targets are computed from the server name found in the
+// Synapse Status.
+func (r *SynapseReconciler) configMapForWellKnown(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
+	baseURL := s.Spec.WellKnown.BaseURL
+	if baseURL == "" {
+		baseURL = "https://" + s.Status.HomeserverConfiguration.ServerName
+	}
+
+	server, err := json.Marshal(map[string]string{"m.server": wellKnownDelegatedServer(s)})
+	if err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+	client, err := json.Marshal(map[string]interface{}{
+		"m.homeserver": map[string]string{"base_url": baseURL},
+	})
+	if err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data: map[string]string{
+			"server":       string(server),
+			"client":       string(client),
+			"default.conf": wellKnownNginxConfig,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	return cm, nil
+}
+
+// reconcileWellKnownDeployment is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the Deployment serving the well-known files to its desired
+// state.
+func (r *SynapseReconciler) reconcileWellKnownDeployment(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForWellKnown := reconcile.SetObjectMeta(GetWellKnownResourceName(*s), s.Namespace, map[string]string{})
+	depl, err := r.deploymentForWellKnown(s, objectMetaForWellKnown)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		depl,
+		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentScheduling(depl),
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// deploymentForWellKnown returns a Deployment object running nginx, serving
+// the well-known files mounted from the well-known ConfigMap. Updates of the
+// files are picked up without restarting the Pod.
+func (r *SynapseReconciler) deploymentForWellKnown(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*appsv1.Deployment, error) {
+	ls := labelsForWellKnown(s.Name)
+	replicas := int32(1)
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					NodeSelector:     s.Spec.NodeSelector,
+					Tolerations:      s.Spec.Tolerations,
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Image: s.Spec.WellKnown.Image,
+						Name:  "nginx",
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "nginx-config",
+							MountPath: "/etc/nginx/conf.d",
+						}, {
+							Name:      "well-known",
+							MountPath: "/usr/share/nginx/html/.well-known/matrix",
+						}},
+						Ports: []corev1.ContainerPort{{
+							Name:          "http",
+							ContainerPort: wellKnownPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: serverWellKnownPath,
+									Port: intstr.FromInt(wellKnownPort),
+								},
+							},
+						},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "nginx-config",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: GetWellKnownResourceName(*s),
+								},
+								Items: []corev1.KeyToPath{{Key: "default.conf", Path: "default.conf"}},
+							},
+						},
+					}, {
+						Name: "well-known",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: GetWellKnownResourceName(*s),
+								},
+								Items: []corev1.KeyToPath{
+									{Key: "server", Path: "server"},
+									{Key: "client", Path: "client"},
+								},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, dep, r.Scheme); err != nil {
+		return &appsv1.Deployment{}, err
+	}
+
+	return dep, nil
+}
+
+// reconcileWellKnownService is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the Service serving the well-known files to its desired
+// state.
+func (r *SynapseReconciler) reconcileWellKnownService(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForWellKnown := reconcile.SetObjectMeta(GetWellKnownResourceName(*s), s.Namespace, map[string]string{})
+	desiredService, err := r.serviceForWellKnown(s, objectMetaForWellKnown)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredService,
+		&corev1.Service{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// serviceForWellKnown returns a Service object for the well-known files.
+// Requests to /.well-known/matrix on the server name must be routed to this
+// Service.
+func (r *SynapseReconciler) serviceForWellKnown(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: objectMeta,
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       wellKnownPort,
+				TargetPort: intstr.FromInt(wellKnownPort),
+			}},
+			Selector: labelsForWellKnown(s.Name),
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
+		return &corev1.Service{}, err
+	}
+
+	return service, nil
+}
+
+// cleanupWellKnown is a function of type FnWithRequest, to be called in the
+// main reconciliation loop.
+//
+// When the well-known delegation is disabled, it deletes the well-known
+// Deployment, Service and ConfigMap left over from a previous
+// configuration, if any.
+func (r *SynapseReconciler) cleanupWellKnown(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForWellKnown := types.NamespacedName{
+		Name:      GetWellKnownResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.ConfigMap{}} {
+		if err := r.Get(ctx, keyForWellKnown, obj); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return subreconciler.RequeueWithError(err)
+		}
+
+		// Only delete resources managed by this Synapse instance
+		if !metav1.IsControlledBy(obj, s) {
+			continue
+		}
+
+		log.Info("Deleting the well-known resource", "Name", keyForWellKnown.Name)
+		if err := r.Delete(ctx, obj); err != nil && !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	return subreconciler.ContinueReconciling()
+}
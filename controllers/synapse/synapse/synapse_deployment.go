@@ -18,10 +18,20 @@ package synapse
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/distribution/reference"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/opdev/subreconciler"
@@ -30,6 +40,16 @@ import (
 	"github.com/opdev/synapse-operator/helpers/utils"
 )
 
+// hashHomeserverValues returns a SHA-256 hash of the given homeserver values
+func hashHomeserverValues(values *synapsev1alpha1.SynapseHomeserverValues) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 // reconcileSynapseDeployment is a function of type FnWithRequest, to be
 // called in the main reconciliation loop.
 //
@@ -51,6 +71,8 @@ func (r *SynapseReconciler) reconcileSynapseDeployment(ctx context.Context, req
 		r.Client,
 		depl,
 		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentScheduling(depl),
+		reconcile.ReplaceDeploymentDNS(depl),
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
@@ -58,16 +80,77 @@ func (r *SynapseReconciler) reconcileSynapseDeployment(ctx context.Context, req
 	return subreconciler.ContinueReconciling()
 }
 
+// Image of Synapse used if none is set in the Synapse Spec
+const defaultSynapseImage = "matrixdotorg/synapse:v1.71.0"
+
+// synapseImage returns the container image of Synapse
+func synapseImage(s *synapsev1alpha1.Synapse) string {
+	if s.Spec.Image != "" {
+		return s.Spec.Image
+	}
+	return defaultSynapseImage
+}
+
+// synapseReplicas returns the number of replicas of the Synapse main process
+func synapseReplicas(s *synapsev1alpha1.Synapse) int32 {
+	if s.Spec.Replicas != nil {
+		return *s.Spec.Replicas
+	}
+	return 1
+}
+
+// isValidImageReference returns true if the given string can be parsed as
+// an image reference
+func isValidImageReference(image string) bool {
+	_, err := reference.ParseNormalizedNamed(image)
+	return err == nil
+}
+
+// hasImageTagOrDigest returns true if the given image reference is pinned to
+// a tag or a sha256 digest
+func hasImageTagOrDigest(image string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+
+	if digested, ok := named.(reference.Digested); ok {
+		return digested.Digest().Algorithm() == "sha256"
+	}
+
+	_, ok := named.(reference.Tagged)
+	return ok
+}
+
 // deploymentForSynapse returns a synapse Deployment object
 func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*appsv1.Deployment, error) {
 	ls := labelsForSynapse(s.Name)
-	replicas := int32(1)
+	replicas := synapseReplicas(s)
 
 	server_name := s.Status.HomeserverConfiguration.ServerName
 	report_stats := s.Status.HomeserverConfiguration.ReportStats
 	// The created Synapse ConfigMap shares the same name as the Synapse deployment
 	synapseConfigMapName := objectMeta.Name
 
+	// The Synapse data is stored on a PVC sharing the same name as the
+	// Synapse deployment, unless ephemeral storage is requested.
+	dataVolumeSource := corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: s.Name,
+		},
+	}
+	if s.Spec.Storage != nil && s.Spec.Storage.Ephemeral {
+		dataVolumeSource = corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		}
+	}
+
+	// The data volume can be mounted from a subdirectory of the volume
+	dataSubPath := ""
+	if s.Spec.Storage != nil {
+		dataSubPath = s.Spec.Storage.SubPath
+	}
+
 	dep := &appsv1.Deployment{
 		ObjectMeta: objectMeta,
 		Spec: appsv1.DeploymentSpec{
@@ -80,10 +163,12 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
 					InitContainers: []corev1.Container{{
-						Image: "matrixdotorg/synapse:v1.71.0",
-						Name:  "synapse-generate",
-						Args:  []string{"generate"},
+						Image:           synapseImage(s),
+						ImagePullPolicy: s.Spec.ImagePullPolicy,
+						Name:            "synapse-generate",
+						Args:            []string{"generate"},
 						Env: []corev1.EnvVar{{
 							Name:  "SYNAPSE_CONFIG_PATH",
 							Value: "/data-homeserver/homeserver.yaml",
@@ -100,11 +185,14 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 						}, {
 							Name:      "data-pv",
 							MountPath: "/data",
+							SubPath:   dataSubPath,
 						}},
 					}},
 					Containers: []corev1.Container{{
-						Image: "matrixdotorg/synapse:v1.71.0",
-						Name:  "synapse",
+						Image:           synapseImage(s),
+						ImagePullPolicy: s.Spec.ImagePullPolicy,
+						Name:            "synapse",
+						Resources:       s.Spec.Resources,
 						Env: []corev1.EnvVar{{
 							Name:  "SYNAPSE_CONFIG_PATH",
 							Value: "/data-homeserver/homeserver.yaml",
@@ -115,10 +203,27 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 						}, {
 							Name:      "data-pv",
 							MountPath: "/data",
+							SubPath:   dataSubPath,
 						}},
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 8008,
 						}},
+						// Running the database migrations can hold the start
+						// of Synapse for several minutes, during which the
+						// liveness probe must not restart the container.
+						StartupProbe: &corev1.Probe{
+							ProbeHandler:     probeHandlerForSynapse(s),
+							PeriodSeconds:    10,
+							FailureThreshold: 60,
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler:        readinessProbeHandlerForSynapse(s),
+							InitialDelaySeconds: 10,
+						},
+						LivenessProbe: &corev1.Probe{
+							ProbeHandler:        probeHandlerForSynapse(s),
+							InitialDelaySeconds: 30,
+						},
 					}},
 					Volumes: []corev1.Volume{{
 						Name: "homeserver",
@@ -130,18 +235,148 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 							},
 						},
 					}, {
-						Name: "data-pv",
-						VolumeSource: corev1.VolumeSource{
-							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-								ClaimName: s.Name,
-							},
-						},
+						Name:         "data-pv",
+						VolumeSource: dataVolumeSource,
 					}},
 				},
 			},
 		},
 	}
 
+	if s.Spec.Homeserver.Values != nil {
+		// The secrets of Synapse are kept out of the homeserver.yaml
+		// ConfigMap, in a secrets file mounted from a Secret. Synapse merges
+		// both configuration files.
+		dep.Spec.Template.Spec.Containers[0].Args = []string{
+			"run",
+			"--config-path", "/data-homeserver/homeserver.yaml",
+			"--config-path", path.Join(homeserverSecretsMountPath, homeserverSecretsFilename),
+		}
+
+		dep.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			dep.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				Name:      "homeserver-secrets",
+				MountPath: homeserverSecretsMountPath,
+				ReadOnly:  true,
+			},
+		)
+
+		dep.Spec.Template.Spec.Volumes = append(
+			dep.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: "homeserver-secrets",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: GetHomeserverSecretsResourceName(*s),
+					},
+				},
+			},
+		)
+	}
+
+	if isMetricsEnabled(s) {
+		dep.Spec.Template.Spec.Containers[0].Ports = append(
+			dep.Spec.Template.Spec.Containers[0].Ports,
+			corev1.ContainerPort{
+				Name:          "metrics",
+				ContainerPort: synapseMetricsPort,
+			},
+		)
+	}
+
+	if isAdminListenerEnabled(s) {
+		dep.Spec.Template.Spec.Containers[0].Ports = append(
+			dep.Spec.Template.Spec.Containers[0].Ports,
+			corev1.ContainerPort{
+				Name:          "admin",
+				ContainerPort: int32(adminListenerPort(s.Spec.Homeserver.Values.AdminListener)),
+			},
+		)
+	}
+
+	if isMediaWorkerEnabled(s) {
+		dep.Spec.Template.Spec.Containers[0].Ports = append(
+			dep.Spec.Template.Spec.Containers[0].Ports,
+			corev1.ContainerPort{
+				Name:          "replication",
+				ContainerPort: synapseReplicationPort,
+			},
+		)
+	}
+
+	if s.Spec.Storage != nil && s.Spec.Storage.MediaStore != nil {
+		// The media store lives on a dedicated PVC, mounted at the
+		// media_store_path configured in homeserver.yaml
+		dep.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			dep.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				Name:      "media-store",
+				MountPath: synapseMediaStorePath,
+			},
+		)
+
+		dep.Spec.Template.Spec.Volumes = append(
+			dep.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: "media-store",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: GetMediaStorePVCResourceName(*s),
+					},
+				},
+			},
+		)
+	}
+
+	if s.Status.LastForcedReconcile != "" {
+		// A forced reconciliation was requested. Changing the Pod template
+		// triggers a rollout of the Deployment, so that Synapse loads the
+		// regenerated configuration.
+		dep.Spec.Template.Annotations = map[string]string{
+			"synapse.opdev.io/restartedAt": s.Status.LastForcedReconcile,
+		}
+	}
+
+	if s.Status.LastMacaroonSecretKeyRotation != "" {
+		// Synapse only reads the macaroon secret key on startup, roll it out
+		// once the key is rotated
+		if dep.Spec.Template.Annotations == nil {
+			dep.Spec.Template.Annotations = map[string]string{}
+		}
+		dep.Spec.Template.Annotations["synapse.opdev.io/macaroonSecretKeyRotatedAt"] = s.Status.LastMacaroonSecretKeyRotation
+	}
+
+	if s.Spec.Homeserver.Values != nil {
+		// Likewise, roll out Synapse when the values homeserver.yaml is
+		// generated from are modified
+		valuesHash, err := hashHomeserverValues(s.Spec.Homeserver.Values)
+		if err != nil {
+			return &appsv1.Deployment{}, err
+		}
+		if dep.Spec.Template.Annotations == nil {
+			dep.Spec.Template.Annotations = map[string]string{}
+		}
+		dep.Spec.Template.Annotations["synapse.opdev.io/valuesHash"] = valuesHash
+	}
+
+	// Fall back to the Kubernetes default if no DNS policy is given, so that
+	// a policy removed from the Spec is reverted
+	dep.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+	if s.Spec.DNSPolicy != "" {
+		dep.Spec.Template.Spec.DNSPolicy = s.Spec.DNSPolicy
+	}
+	dep.Spec.Template.Spec.DNSConfig = s.Spec.DNSConfig
+
+	// Leave the scheduling untouched if no constraints are given
+	dep.Spec.Template.Spec.NodeSelector = s.Spec.NodeSelector
+	dep.Spec.Template.Spec.Tolerations = s.Spec.Tolerations
+	dep.Spec.Template.Spec.Affinity = s.Spec.Affinity
+
+	if s.Spec.CreateNewPostgreSQL && s.Spec.Database != nil && s.Spec.Database.ColocateWithSynapse {
+		dep.Spec.Template.Spec.Affinity = affinityForPostgresCluster(s)
+	}
+
 	if s.Spec.IsOpenshift {
 		// Synapse must run with user 991.
 		// If deploying on Openshift, we must run the workload with a Service
@@ -156,7 +391,7 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 			dep.Spec.Template.Spec.Containers[0].VolumeMounts,
 			corev1.VolumeMount{
 				Name:      "data-heisenbridge",
-				MountPath: "/data-heisenbridge",
+				MountPath: utils.HeisenbridgeConfigDir,
 			},
 		)
 
@@ -205,6 +440,25 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 		)
 	}
 
+	if s.Spec.Homeserver.Values != nil {
+		pythonPath := []string{}
+		if isAdminListenerEnabled(s) {
+			// The module blocking the admin API on the public listeners is
+			// shipped in the Synapse ConfigMap
+			pythonPath = append(pythonPath, "/data-homeserver")
+		}
+		addModuleVolumes(dep, s.Spec.Homeserver.Values.Modules, pythonPath)
+	}
+
+	if s.Spec.Performance != nil {
+		dep.Spec.Template.Spec.Containers[0].Env = append(
+			dep.Spec.Template.Spec.Containers[0].Env,
+			envForPerformance(s.Spec.Performance)...,
+		)
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(s, dep, r.Scheme); err != nil {
 		return &appsv1.Deployment{}, err
@@ -212,3 +466,143 @@ func (r *SynapseReconciler) deploymentForSynapse(s *synapsev1alpha1.Synapse, obj
 
 	return dep, nil
 }
+
+// addModuleVolumes mounts the ConfigMaps and PVCs holding the sources of the
+// given Synapse modules in the Synapse container, and sets the PYTHONPATH to
+// the given directories followed by the module directories.
+func addModuleVolumes(dep *appsv1.Deployment, modules []synapsev1alpha1.SynapseHomeserverModule, pythonPath []string) {
+	for i, module := range modules {
+		var volumeSource corev1.VolumeSource
+		switch {
+		case module.ConfigMap != "":
+			volumeSource.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: module.ConfigMap},
+			}
+		case module.PersistentVolumeClaim != "":
+			volumeSource.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: module.PersistentVolumeClaim,
+				ReadOnly:  true,
+			}
+		default:
+			// The module is shipped with the Synapse image
+			continue
+		}
+
+		volumeName := "module-" + strconv.Itoa(i)
+		mountPath := "/modules/" + volumeName
+		pythonPath = append(pythonPath, mountPath)
+
+		dep.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			dep.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			},
+		)
+		dep.Spec.Template.Spec.Volumes = append(
+			dep.Spec.Template.Spec.Volumes,
+			corev1.Volume{Name: volumeName, VolumeSource: volumeSource},
+		)
+	}
+
+	if len(pythonPath) > 0 {
+		dep.Spec.Template.Spec.Containers[0].Env = append(
+			dep.Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{Name: "PYTHONPATH", Value: strings.Join(pythonPath, ":")},
+		)
+	}
+}
+
+// Matches the name of a Synapse cache which can be tuned through an
+// environment variable
+var cacheNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// isValidCacheFactor returns true if the given string is a cache factor
+// accepted by Synapse, that is a number greater than 0
+func isValidCacheFactor(factor string) bool {
+	f, err := strconv.ParseFloat(factor, 64)
+	return err == nil && f > 0
+}
+
+// envForPerformance returns the environment variables of the Synapse
+// container implementing the given performance tuning options. Per-cache
+// factors are sorted by cache name, so that the Deployment isn't rolled out
+// at each reconciliation.
+func envForPerformance(performance *synapsev1alpha1.SynapsePerformance) []corev1.EnvVar {
+	env := []corev1.EnvVar{}
+
+	if performance.AsyncIOReactor {
+		env = append(env, corev1.EnvVar{Name: "SYNAPSE_ASYNC_IO_REACTOR", Value: "1"})
+	}
+
+	if performance.CacheFactor != "" {
+		env = append(env, corev1.EnvVar{Name: "SYNAPSE_CACHE_FACTOR", Value: performance.CacheFactor})
+	}
+
+	cacheNames := make([]string, 0, len(performance.PerCacheFactors))
+	for cacheName := range performance.PerCacheFactors {
+		cacheNames = append(cacheNames, cacheName)
+	}
+	sort.Strings(cacheNames)
+	for _, cacheName := range cacheNames {
+		env = append(env, corev1.EnvVar{
+			Name:  "SYNAPSE_CACHE_FACTOR_" + strings.ToUpper(cacheName),
+			Value: performance.PerCacheFactors[cacheName],
+		})
+	}
+
+	return env
+}
+
+// probeHandlerForSynapse returns the handler used by the startup, readiness
+// and liveness probes of the Synapse container. The probed path defaults to
+// /health and can be changed with Spec.Probes.Path.
+func probeHandlerForSynapse(s *synapsev1alpha1.Synapse) corev1.ProbeHandler {
+	path := "/health"
+	if s.Spec.Probes != nil && s.Spec.Probes.Path != "" {
+		path = s.Spec.Probes.Path
+	}
+
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: path,
+			Port: intstr.FromInt(8008),
+		},
+	}
+}
+
+// readinessProbeHandlerForSynapse returns the handler used by the readiness
+// probe of the Synapse container. With Spec.Probes.ReadinessOnClientAPI, it
+// queries /_matrix/client/versions, which only answers once the client API is
+// served.
+func readinessProbeHandlerForSynapse(s *synapsev1alpha1.Synapse) corev1.ProbeHandler {
+	handler := probeHandlerForSynapse(s)
+	if s.Spec.Probes != nil && s.Spec.Probes.ReadinessOnClientAPI {
+		handler.HTTPGet.Path = "/_matrix/client/versions"
+	}
+
+	return handler
+}
+
+// affinityForPostgresCluster returns a preferred pod affinity towards the
+// primary pod of the PostgresCluster, using the labels set by the
+// crunchydata postgres-operator.
+func affinityForPostgresCluster(s *synapsev1alpha1.Synapse) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"postgres-operator.crunchydata.com/cluster": GetPostgresClusterResourceName(*s),
+							"postgres-operator.crunchydata.com/role":    "master",
+						},
+					},
+					TopologyKey: "kubernetes.io/hostname",
+				},
+			}},
+		},
+	}
+}
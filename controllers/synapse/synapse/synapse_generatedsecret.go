@@ -0,0 +1,249 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// generatedSecret describes a Secret holding a random value generated by the
+// operator, and the homeserver setting configured with this value
+type generatedSecret struct {
+	// Returns the name of the Secret for the given Synapse instance
+	name func(synapsev1alpha1.Synapse) string
+	// Key of the Secret holding the generated value
+	key string
+	// Setting of the homeserver secrets file set to the generated value.
+	// Left empty if the value isn't configured in the secrets file as is.
+	setting string
+}
+
+var (
+	registrationSharedSecret = generatedSecret{
+		name:    GetRegistrationSharedSecretResourceName,
+		key:     registrationSharedSecretKey,
+		setting: "registration_shared_secret",
+	}
+	macaroonSecretKey = generatedSecret{
+		name:    GetMacaroonSecretKeyResourceName,
+		key:     macaroonSecretKeyKey,
+		setting: "macaroon_secret_key",
+	}
+	workerReplicationSecret = generatedSecret{
+		name:    GetWorkerReplicationSecretResourceName,
+		key:     workerReplicationSecretKey,
+		setting: "worker_replication_secret",
+	}
+	coturnSharedSecret = generatedSecret{
+		name: GetCoturnResourceName,
+		key:  coturnSharedSecretKey,
+	}
+)
+
+// reconcileGeneratedSecret is a function of type FnWithRequest, once given
+// the generatedSecret, to be called in the main reconciliation loop.
+//
+// It creates the Secret holding a newly generated value. The Secret is only
+// created if it doesn't exist yet, so that the value isn't rotated at each
+// reconciliation.
+func (r *SynapseReconciler) reconcileGeneratedSecret(ctx context.Context, req ctrl.Request, gs generatedSecret) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSecret := types.NamespacedName{
+		Name:      gs.name(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := r.Get(ctx, keyForSecret, &corev1.Secret{}); err == nil {
+		return subreconciler.ContinueReconciling()
+	} else if !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	objectMeta := reconcile.SetObjectMeta(gs.name(*s), s.Namespace, map[string]string{})
+	secret, err := r.secretForGeneratedSecret(s, objectMeta, gs)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	log.Info("Creating generated secret", "Secret.Name", secret.Name, "Key", gs.key)
+	if err := r.Create(ctx, secret); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// secretForGeneratedSecret returns a Secret object, holding a newly generated
+// value in the key of gs
+func (r *SynapseReconciler) secretForGeneratedSecret(
+	s *synapsev1alpha1.Synapse,
+	objectMeta metav1.ObjectMeta,
+	gs generatedSecret,
+) (*corev1.Secret, error) {
+	value, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: objectMeta,
+		StringData: map[string]string{gs.key: value},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, secret, r.Scheme); err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// updateHomeserverSecretsForGeneratedSecret is a function of type
+// FnWithRequest, once given the generatedSecret, to be called in the main
+// reconciliation loop.
+//
+// It configures the setting of gs in the homeserver secrets file with the
+// generated value.
+func (r *SynapseReconciler) updateHomeserverSecretsForGeneratedSecret(ctx context.Context, req ctrl.Request, gs generatedSecret) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var secret corev1.Secret
+	keyForSecret := types.NamespacedName{
+		Name:      gs.name(*s),
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForSecret, &secret); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return updateHomeserverWithGeneratedSecret(homeserver, secret, gs)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithGeneratedSecret sets the setting of gs in the
+// homeserver secrets file to the value held by secret
+func updateHomeserverWithGeneratedSecret(
+	homeserver map[string]interface{},
+	secret corev1.Secret,
+	gs generatedSecret,
+) error {
+	value, ok := secret.Data[gs.key]
+	if !ok {
+		return errors.New("missing " + gs.key + " key in Secret " + secret.Name)
+	}
+
+	homeserver[gs.setting] = string(value)
+	return nil
+}
+
+// removeGeneratedSecret is a function of type FnWithRequest, once given the
+// generatedSecret, to be called in the main reconciliation loop.
+//
+// It removes the setting of gs from the homeserver secrets file, and deletes
+// the generated Secret left over from a previous configuration, if any.
+func (r *SynapseReconciler) removeGeneratedSecret(ctx context.Context, req ctrl.Request, gs generatedSecret) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return updateHomeserverWithoutGeneratedSecret(homeserver, gs)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	secret := &corev1.Secret{}
+	keyForSecret := types.NamespacedName{
+		Name:      gs.name(*s),
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForSecret, secret); err == nil {
+		// Only delete a Secret managed by this Synapse instance
+		if metav1.IsControlledBy(secret, s) {
+			log.Info("Deleting generated secret", "Secret.Name", secret.Name, "Key", gs.key)
+			if err := r.Delete(ctx, secret); err != nil && !k8serrors.IsNotFound(err) {
+				return subreconciler.RequeueWithError(err)
+			}
+		}
+	} else if !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithoutGeneratedSecret removes the setting of gs from the
+// homeserver secrets file
+func updateHomeserverWithoutGeneratedSecret(homeserver map[string]interface{}, gs generatedSecret) error {
+	delete(homeserver, gs.setting)
+	return nil
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+)
+
+func GetGrafanaDashboardResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "grafana-dashboard"}, "-")
+}
+
+// reconcileSynapseGrafanaDashboard is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It reconciles the ConfigMap holding the Grafana dashboard for Synapse. The
+// ConfigMap is labeled with grafana_dashboard: "1", so that it is picked up
+// by the Grafana dashboard sidecar.
+func (r *SynapseReconciler) reconcileSynapseGrafanaDashboard(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForDashboard := reconcile.SetObjectMeta(
+		GetGrafanaDashboardResourceName(*s),
+		s.Namespace,
+		map[string]string{"grafana_dashboard": "1"},
+	)
+
+	desiredConfigMap, err := r.configMapForGrafanaDashboard(s, objectMetaForDashboard)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredConfigMap,
+		&corev1.ConfigMap{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// cleanupSynapseGrafanaDashboard is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// When the Grafana dashboard is disabled, it deletes the ConfigMap holding
+// the dashboard provisioned previously, if any.
+func (r *SynapseReconciler) cleanupSynapseGrafanaDashboard(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	cm := &corev1.ConfigMap{}
+	keyForDashboard := types.NamespacedName{
+		Name:      GetGrafanaDashboardResourceName(*s),
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForDashboard, cm); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return subreconciler.ContinueReconciling()
+		}
+		return subreconciler.RequeueWithError(err)
+	}
+
+	// Only delete a dashboard managed by this Synapse instance
+	if !metav1.IsControlledBy(cm, s) {
+		return subreconciler.ContinueReconciling()
+	}
+
+	log.Info("Deleting the Grafana dashboard", "ConfigMap.Name", cm.Name)
+	if err := r.Delete(ctx, cm); err != nil && !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// configMapForGrafanaDashboard returns a ConfigMap object holding the
+// Grafana dashboard for Synapse
+func (r *SynapseReconciler) configMapForGrafanaDashboard(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
+	dashboard, err := grafanaDashboardForSynapse(s)
+	if err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data:       map[string]string{"synapse.json": dashboard},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	return cm, nil
+}
+
+// grafanaDashboardForSynapse returns the Grafana dashboard of the given
+// Synapse instance. Its uid and title are derived from the namespace and name
+// of the Synapse instance, so that the dashboards of several instances don't
+// overwrite each other in Grafana.
+func grafanaDashboardForSynapse(s *synapsev1alpha1.Synapse) (string, error) {
+	dashboard := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(synapseGrafanaDashboard), &dashboard); err != nil {
+		return "", err
+	}
+
+	// Grafana limits the uid to 40 characters
+	hash := sha256.Sum256([]byte(s.Namespace + "/" + s.Name))
+	dashboard["uid"] = "synapse-" + hex.EncodeToString(hash[:])[:16]
+	dashboard["title"] = "Synapse (" + s.Namespace + "/" + s.Name + ")"
+
+	out, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// synapseGrafanaDashboard is a Grafana dashboard covering the main Synapse
+// metrics. The Prometheus datasource and the job scraping Synapse are
+// selected with dashboard variables. The uid and title are set by
+// grafanaDashboardForSynapse.
+const synapseGrafanaDashboard = `{
+  "tags": ["matrix", "synapse"],
+  "timezone": "browser",
+  "schemaVersion": 37,
+  "refresh": "1m",
+  "time": {"from": "now-6h", "to": "now"},
+  "templating": {
+    "list": [
+      {
+        "name": "datasource",
+        "label": "Datasource",
+        "type": "datasource",
+        "query": "prometheus"
+      },
+      {
+        "name": "job",
+        "label": "Job",
+        "type": "query",
+        "datasource": {"type": "prometheus", "uid": "${datasource}"},
+        "query": "label_values(synapse_build_info, job)",
+        "refresh": 2,
+        "includeAll": true,
+        "multi": true
+      }
+    ]
+  },
+  "panels": [
+    {
+      "id": 1,
+      "title": "CPU usage",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 0, "y": 0},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "percentunit"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "rate(process_cpu_seconds_total{job=~\"$job\"}[$__rate_interval])",
+          "legendFormat": "{{instance}}"
+        }
+      ]
+    },
+    {
+      "id": 2,
+      "title": "Memory usage",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 12, "y": 0},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "bytes"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "process_resident_memory_bytes{job=~\"$job\"}",
+          "legendFormat": "{{instance}}"
+        }
+      ]
+    },
+    {
+      "id": 3,
+      "title": "HTTP requests received",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 0, "y": 8},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "reqps"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "sum by (servlet) (rate(synapse_http_server_requests_received_total{job=~\"$job\"}[$__rate_interval]))",
+          "legendFormat": "{{servlet}}"
+        }
+      ]
+    },
+    {
+      "id": 4,
+      "title": "HTTP response time (p95)",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 12, "y": 8},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "s"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "histogram_quantile(0.95, sum by (le, servlet) (rate(synapse_http_server_response_time_seconds_bucket{job=~\"$job\"}[$__rate_interval])))",
+          "legendFormat": "{{servlet}}"
+        }
+      ]
+    },
+    {
+      "id": 5,
+      "title": "Events persisted",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 0, "y": 16},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "ops"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "rate(synapse_storage_events_persisted_events_total{job=~\"$job\"}[$__rate_interval])",
+          "legendFormat": "{{instance}}"
+        }
+      ]
+    },
+    {
+      "id": 6,
+      "title": "Federation transactions",
+      "type": "timeseries",
+      "gridPos": {"h": 8, "w": 12, "x": 12, "y": 16},
+      "datasource": {"type": "prometheus", "uid": "${datasource}"},
+      "fieldConfig": {"defaults": {"unit": "ops"}, "overrides": []},
+      "targets": [
+        {
+          "expr": "rate(synapse_federation_client_sent_transactions_total{job=~\"$job\"}[$__rate_interval])",
+          "legendFormat": "sent"
+        },
+        {
+          "expr": "rate(synapse_federation_server_received_pdus_total{job=~\"$job\"}[$__rate_interval])",
+          "legendFormat": "received PDUs"
+        }
+      ]
+    }
+  ]
+}
+`
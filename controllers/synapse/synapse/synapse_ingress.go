@@ -0,0 +1,225 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+)
+
+// reconcileSynapseIngress is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It reconciles the Ingress for Synapse to its desired state.
+func (r *SynapseReconciler) reconcileSynapseIngress(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForSynapse := reconcile.SetObjectMeta(s.Name, s.Namespace, map[string]string{})
+	desiredIngress, err := r.ingressForSynapse(s, objectMetaForSynapse)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredIngress,
+		&networkingv1.Ingress{},
+		func(current client.Object) {
+			// The annotations, class, TLS and routed hosts follow the Spec,
+			// even when some of them are removed
+			current.SetAnnotations(desiredIngress.Annotations)
+			current.(*networkingv1.Ingress).Spec.IngressClassName = desiredIngress.Spec.IngressClassName
+			current.(*networkingv1.Ingress).Spec.TLS = desiredIngress.Spec.TLS
+			current.(*networkingv1.Ingress).Spec.Rules = desiredIngress.Spec.Rules
+		},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// ingressForSynapse returns a Synapse Ingress object. The client endpoints
+// are routed to the Synapse Service on the Ingress host. If enabled, the
+// federation endpoints are routed on the federation host. When the media
+// worker is enabled, the media endpoints are routed to the worker instead.
+// When the well-known delegation is enabled, /.well-known/matrix is routed
+// to it on the server name.
+func (r *SynapseReconciler) ingressForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*networkingv1.Ingress, error) {
+	spec := s.Spec.Ingress
+	objectMeta.Annotations = spec.Annotations
+
+	hosts := []string{spec.Host}
+	// /_synapse/admin is deliberately not routed, see
+	// Spec.Homeserver.Values.AdminListener
+	rules := []networkingv1.IngressRule{
+		ingressRuleForSynapse(s, spec.Host, []string{"/_matrix", "/_synapse/client"}),
+	}
+
+	if spec.Federation != nil && spec.Federation.Enabled {
+		federationHost := federationHostForIngress(s)
+		if federationHost != spec.Host {
+			// /_matrix is already routed on the client host
+			federationPaths := []string{"/_matrix/federation", "/_matrix/key"}
+			hosts = append(hosts, federationHost)
+			rules = append(rules, ingressRuleForSynapse(s, federationHost, federationPaths))
+		}
+	}
+
+	if isWellKnownDelegationEnabled(s) {
+		// The well-known files are served on the server name, which may
+		// already be one of the Ingress hosts
+		serverName := s.Status.HomeserverConfiguration.ServerName
+		wellKnownPath := ingressPath("/.well-known/matrix", GetWellKnownResourceName(*s), wellKnownPort)
+		routed := false
+		for i := range rules {
+			if rules[i].Host == serverName {
+				rules[i].HTTP.Paths = append(rules[i].HTTP.Paths, wellKnownPath)
+				routed = true
+			}
+		}
+		if !routed {
+			hosts = append(hosts, serverName)
+			rules = append(rules, networkingv1.IngressRule{
+				Host: serverName,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{wellKnownPath},
+					},
+				},
+			})
+		}
+	}
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: objectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules:            rules,
+		},
+	}
+
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{
+			Hosts:      hosts,
+			SecretName: spec.TLSSecretName,
+		}}
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, ingress, r.Scheme); err != nil {
+		return &networkingv1.Ingress{}, err
+	}
+
+	return ingress, nil
+}
+
+// ingressRuleForSynapse returns an Ingress rule routing the given path
+// prefixes of host to the Synapse Service, and the media endpoints to the
+// media worker if it is enabled
+func ingressRuleForSynapse(s *synapsev1alpha1.Synapse, host string, prefixes []string) networkingv1.IngressRule {
+	paths := []networkingv1.HTTPIngressPath{}
+	for _, prefix := range prefixes {
+		paths = append(paths, ingressPath(prefix, s.Name, 8008))
+	}
+
+	if isMediaWorkerEnabled(s) {
+		// The longest matching prefix wins over /_matrix
+		paths = append(paths, ingressPath("/_matrix/media", GetMediaWorkerResourceName(*s), mediaWorkerPort))
+	}
+
+	return networkingv1.IngressRule{
+		Host: host,
+		IngressRuleValue: networkingv1.IngressRuleValue{
+			HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+		},
+	}
+}
+
+// ingressPath returns an Ingress path routing the given prefix to the given
+// Service port
+func ingressPath(prefix string, serviceName string, port int32) networkingv1.HTTPIngressPath {
+	pathType := networkingv1.PathTypePrefix
+	return networkingv1.HTTPIngressPath{
+		Path:     prefix,
+		PathType: &pathType,
+		Backend: networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{
+				Name: serviceName,
+				Port: networkingv1.ServiceBackendPort{Number: port},
+			},
+		},
+	}
+}
+
+// federationHostForIngress returns the host on which the federation
+// endpoints are exposed, defaulting to the server name
+func federationHostForIngress(s *synapsev1alpha1.Synapse) string {
+	if s.Spec.Ingress.Federation.Host != "" {
+		return s.Spec.Ingress.Federation.Host
+	}
+	return s.Status.HomeserverConfiguration.ServerName
+}
+
+// cleanupSynapseIngress is a function of type FnWithRequest, to be called in
+// the main reconciliation loop.
+//
+// When no Ingress is configured, it deletes the Synapse Ingress left over
+// from a previous configuration, if any.
+func (r *SynapseReconciler) cleanupSynapseIngress(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err := r.Get(ctx, types.NamespacedName{Name: s.Name, Namespace: s.Namespace}, ingress); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return subreconciler.ContinueReconciling()
+		}
+		return subreconciler.RequeueWithError(err)
+	}
+
+	// Only delete an Ingress managed by this Synapse instance
+	if !metav1.IsControlledBy(ingress, s) {
+		return subreconciler.ContinueReconciling()
+	}
+
+	log.Info("Deleting the Synapse Ingress", "Name", ingress.Name)
+	if err := r.Delete(ctx, ingress); err != nil && !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
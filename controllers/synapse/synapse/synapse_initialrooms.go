@@ -0,0 +1,405 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Key of the Secret holding the password of the admin user creating the
+// initial rooms
+const initialRoomsPasswordKey = "password"
+
+// Annotation of the initial rooms Job, holding the hash of the ConfigMap it
+// was created from
+const initialRoomsHashAnnotation = "synapse.opdev.io/initial-rooms-hash"
+
+// createRoomsScript is run by the initial rooms Job. It registers the
+// @synapse-operator admin user with the registration shared secret, if it
+// doesn't exist yet, logs in and creates the rooms listed in rooms.json
+// whose alias doesn't exist yet.
+const createRoomsScript = `import hashlib
+import hmac
+import json
+import os
+import sys
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+
+SYNAPSE_URL = os.environ["SYNAPSE_URL"]
+SERVER_NAME = os.environ["SERVER_NAME"]
+BOT_USER = "synapse-operator"
+
+
+def request(method, path, body=None, token=None):
+    data = json.dumps(body).encode() if body is not None else None
+    req = urllib.request.Request(SYNAPSE_URL + path, data=data, method=method)
+    req.add_header("Content-Type", "application/json")
+    if token:
+        req.add_header("Authorization", "Bearer " + token)
+    try:
+        with urllib.request.urlopen(req) as resp:
+            return resp.status, json.load(resp)
+    except urllib.error.HTTPError as e:
+        try:
+            return e.code, json.load(e)
+        except ValueError:
+            return e.code, {}
+
+
+def wait_for_synapse():
+    for _ in range(60):
+        try:
+            urllib.request.urlopen(SYNAPSE_URL + "/health")
+            return
+        except (urllib.error.URLError, ConnectionError):
+            time.sleep(5)
+    sys.exit("Synapse is not reachable at " + SYNAPSE_URL)
+
+
+def register_bot(password):
+    _, body = request("GET", "/_synapse/admin/v1/register")
+    nonce = body["nonce"]
+    mac = hmac.new(os.environ["REGISTRATION_SHARED_SECRET"].encode(), digestmod=hashlib.sha1)
+    mac.update(b"\x00".join([nonce.encode(), BOT_USER.encode(), password.encode(), b"admin"]))
+    status, body = request("POST", "/_synapse/admin/v1/register", {
+        "nonce": nonce,
+        "username": BOT_USER,
+        "password": password,
+        "admin": True,
+        "mac": mac.hexdigest(),
+    })
+    if status != 200 and body.get("errcode") != "M_USER_IN_USE":
+        sys.exit("Failed to register " + BOT_USER + ": " + json.dumps(body))
+
+
+def login(password):
+    status, body = request("POST", "/_matrix/client/v3/login", {
+        "type": "m.login.password",
+        "identifier": {"type": "m.id.user", "user": BOT_USER},
+        "password": password,
+    })
+    if status != 200:
+        sys.exit("Failed to log in as " + BOT_USER + ": " + json.dumps(body))
+    return body["access_token"]
+
+
+def create_room(room, token):
+    alias = "#" + room["alias"] + ":" + SERVER_NAME
+    status, _ = request("GET", "/_matrix/client/v3/directory/room/" + urllib.parse.quote(alias), token=token)
+    if status == 200:
+        print("Skipping " + alias + ", which already exists")
+        return
+
+    body = {
+        "room_alias_name": room["alias"],
+        "preset": "public_chat" if room.get("public") else "private_chat",
+        "visibility": "public" if room.get("public") else "private",
+    }
+    if room.get("name"):
+        body["name"] = room["name"]
+    if room.get("topic"):
+        body["topic"] = room["topic"]
+    if room.get("space"):
+        body["creation_content"] = {"type": "m.space"}
+
+    status, resp = request("POST", "/_matrix/client/v3/createRoom", body, token)
+    if status != 200:
+        sys.exit("Failed to create " + alias + ": " + json.dumps(resp))
+    print("Created " + alias)
+
+
+def main():
+    with open("/initial-rooms/rooms.json") as f:
+        rooms = json.load(f)
+
+    wait_for_synapse()
+    password = os.environ["BOT_PASSWORD"]
+    register_bot(password)
+    token = login(password)
+    for room in rooms:
+        create_room(room, token)
+
+
+main()
+`
+
+func GetInitialRoomsResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "initial-rooms"}, "-")
+}
+
+// reconcileInitialRoomsSecret is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It creates the Secret holding the password of the @synapse-operator admin
+// user, which creates the initial rooms. The Secret is only created if it
+// doesn't exist yet, as the user keeps its first password.
+func (r *SynapseReconciler) reconcileInitialRoomsSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSecret := types.NamespacedName{
+		Name:      GetInitialRoomsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := r.Get(ctx, keyForSecret, &corev1.Secret{}); err == nil {
+		return subreconciler.ContinueReconciling()
+	} else if !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetInitialRoomsResourceName(*s), s.Namespace, map[string]string{})
+	secret, err := r.secretForInitialRooms(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	log.Info("Creating initial rooms Secret", "Secret.Name", secret.Name)
+	if err := r.Create(ctx, secret); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// secretForInitialRooms returns a Secret object, holding a newly generated
+// password for the @synapse-operator admin user
+func (r *SynapseReconciler) secretForInitialRooms(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Secret, error) {
+	password, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: objectMeta,
+		StringData: map[string]string{initialRoomsPasswordKey: password},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, secret, r.Scheme); err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// reconcileInitialRoomsConfigMap is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the ConfigMap holding the list of initial rooms and the
+// script creating them to its desired state.
+func (r *SynapseReconciler) reconcileInitialRoomsConfigMap(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetInitialRoomsResourceName(*s), s.Namespace, map[string]string{})
+	desiredConfigMap, err := r.configMapForInitialRooms(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredConfigMap,
+		&corev1.ConfigMap{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// configMapForInitialRooms returns a ConfigMap object holding the list of
+// initial rooms, in JSON, and the script creating them
+func (r *SynapseReconciler) configMapForInitialRooms(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
+	rooms, err := json.Marshal(s.Spec.InitialRooms)
+	if err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data: map[string]string{
+			"rooms.json":      string(rooms),
+			"create_rooms.py": createRoomsScript,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	return cm, nil
+}
+
+// reconcileInitialRoomsJob is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It creates the one-shot Job creating the initial rooms. As the Pod
+// template of a Job is immutable, the Job is deleted and created again when
+// the list of initial rooms changes. Rooms which already exist are skipped
+// by the Job.
+func (r *SynapseReconciler) reconcileInitialRoomsJob(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetInitialRoomsResourceName(*s), s.Namespace, map[string]string{})
+	cm, err := r.configMapForInitialRooms(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+	desiredJob, err := r.jobForInitialRooms(s, objectMeta, hashConfigMapData(cm.Data))
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	currentJob := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{Name: desiredJob.Name, Namespace: desiredJob.Namespace}, currentJob); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+
+		log.Info("Creating initial rooms Job", "Job.Name", desiredJob.Name)
+		if err := r.Create(ctx, desiredJob); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.ContinueReconciling()
+	}
+
+	if currentJob.Annotations[initialRoomsHashAnnotation] == desiredJob.Annotations[initialRoomsHashAnnotation] {
+		return subreconciler.ContinueReconciling()
+	}
+
+	log.Info("Initial rooms changed, deleting the previous Job", "Job.Name", currentJob.Name)
+	if err := r.Delete(ctx, currentJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.Requeue()
+}
+
+// jobForInitialRooms returns a Job object creating the initial rooms
+func (r *SynapseReconciler) jobForInitialRooms(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta, hash string) (*batchv1.Job, error) {
+	objectMeta.Annotations = map[string]string{initialRoomsHashAnnotation: hash}
+
+	// The Job registers its user with the admin API, which is only served
+	// on the admin listener if it is enabled
+	synapsePort := 8008
+	if isAdminListenerEnabled(s) {
+		synapsePort = adminListenerPort(s.Spec.Homeserver.Values.AdminListener)
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: objectMeta,
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyOnFailure,
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Image:           synapseImage(s),
+						ImagePullPolicy: s.Spec.ImagePullPolicy,
+						Name:            "initial-rooms",
+						Command:         []string{"python3", "/initial-rooms/create_rooms.py"},
+						Env: []corev1.EnvVar{{
+							Name:  "SYNAPSE_URL",
+							Value: "http://" + utils.ComputeFQDN(s.Name, s.Namespace) + ":" + strconv.Itoa(synapsePort),
+						}, {
+							Name:  "SERVER_NAME",
+							Value: s.Status.HomeserverConfiguration.ServerName,
+						}, {
+							Name: "REGISTRATION_SHARED_SECRET",
+							ValueFrom: &corev1.EnvVarSource{
+								SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: getRegistrationSharedSecretName(s),
+									},
+									Key: registrationSharedSecretKey,
+								},
+							},
+						}, {
+							Name: "BOT_PASSWORD",
+							ValueFrom: &corev1.EnvVarSource{
+								SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: GetInitialRoomsResourceName(*s),
+									},
+									Key: initialRoomsPasswordKey,
+								},
+							},
+						}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "initial-rooms",
+							MountPath: "/initial-rooms",
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "initial-rooms",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: GetInitialRoomsResourceName(*s),
+								},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	utils.SetDefaultRequests(&job.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, job, r.Scheme); err != nil {
+		return &batchv1.Job{}, err
+	}
+
+	return job, nil
+}
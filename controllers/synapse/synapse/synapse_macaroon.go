@@ -0,0 +1,109 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+)
+
+// Key of the Secret holding the macaroon secret key
+const macaroonSecretKeyKey = "macaroon_secret_key"
+
+func GetMacaroonSecretKeyResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "macaroon"}, "-")
+}
+
+// processRotateMacaroonSecretKeyAnnotation is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// If the Synapse object holds the synapse.opdev.io/rotate-macaroon-secret-key
+// annotation, it deletes the Secret holding the macaroon_secret_key, so that
+// a new key is generated by reconcileMacaroonSecretKey, and records the
+// rotation in the Synapse Status, which triggers the rollout of the Synapse
+// Deployment. The annotation is then removed. Rotating
+// the key invalidates all access tokens, logging out every user.
+func (r *SynapseReconciler) processRotateMacaroonSecretKeyAnnotation(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if _, ok := s.Annotations[synapsev1alpha1.RotateMacaroonSecretKeyAnnotation]; !ok {
+		return subreconciler.ContinueReconciling()
+	}
+
+	log.Info("Rotating the macaroon secret key", "Synapse Name", s.Name)
+
+	secret := &corev1.Secret{
+		ObjectMeta: reconcile.SetObjectMeta(GetMacaroonSecretKeyResourceName(*s), s.Namespace, map[string]string{}),
+	}
+	if err := r.Delete(ctx, secret); err != nil && !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	// Synapse only reads the key on startup
+	s.Status.LastMacaroonSecretKeyRotation = metav1.Now().Format(time.RFC3339)
+	if err, _ := r.updateSynapseStatus(ctx, s); err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	// Remove the annotation so that the key is rotated only once
+	patch := client.MergeFrom(s.DeepCopy())
+	delete(s.Annotations, synapsev1alpha1.RotateMacaroonSecretKeyAnnotation)
+	if err := r.Patch(ctx, s, patch); err != nil {
+		log.Error(err, "Error removing the rotate-macaroon-secret-key annotation")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.Requeue()
+}
+
+// reconcileMacaroonSecretKey is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It creates the Secret holding the macaroon_secret_key, used by Synapse to
+// sign access tokens. An existing key is never regenerated, as doing so would
+// invalidate all access tokens. The key is only rotated on request, through
+// the synapse.opdev.io/rotate-macaroon-secret-key annotation.
+func (r *SynapseReconciler) reconcileMacaroonSecretKey(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.reconcileGeneratedSecret(ctx, req, macaroonSecretKey)
+}
+
+// updateHomeserverSecretsForMacaroonSecretKey is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It configures the 'macaroon_secret_key' of the homeserver secrets file
+// with the generated key.
+func (r *SynapseReconciler) updateHomeserverSecretsForMacaroonSecretKey(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.updateHomeserverSecretsForGeneratedSecret(ctx, req, macaroonSecretKey)
+}
@@ -0,0 +1,456 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+const (
+	// Port on which the media repository worker serves the media endpoints
+	mediaWorkerPort = 8085
+	// Port of the replication listener of the Synapse main process, used by
+	// the workers
+	synapseReplicationPort = 9093
+	// Name of the media repository worker, as referenced in homeserver.yaml
+	mediaWorkerName = "media_repository"
+	// Directory in which the worker configuration file is mounted
+	mediaWorkerConfigMountPath = "/data-worker"
+)
+
+func GetMediaWorkerResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "media", "worker"}, "-")
+}
+
+// labelsForMediaWorker returns the labels for selecting the media repository
+// worker resources belonging to the given synapse CR name.
+func labelsForMediaWorker(name string) map[string]string {
+	return map[string]string{"app": "synapse-media", "synapse_cr": name}
+}
+
+// isMediaWorkerEnabled returns true if a media repository worker is deployed
+// alongside Synapse
+func isMediaWorkerEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.MediaWorker != nil && s.Spec.MediaWorker.Enabled
+}
+
+// reconcileMediaWorkerConfigMap is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the ConfigMap holding the worker configuration file of the
+// media repository worker to its desired state.
+func (r *SynapseReconciler) reconcileMediaWorkerConfigMap(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForMediaWorker := reconcile.SetObjectMeta(GetMediaWorkerResourceName(*s), s.Namespace, map[string]string{})
+	desiredConfigMap, err := r.configMapForMediaWorker(s, objectMetaForMediaWorker)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredConfigMap,
+		&corev1.ConfigMap{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// configMapForMediaWorker returns a ConfigMap object holding the worker.yaml
+// configuration file of the media repository worker. It is loaded on top of
+// homeserver.yaml and of the secrets file of the main process.
+func (r *SynapseReconciler) configMapForMediaWorker(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.ConfigMap, error) {
+	worker, err := yaml.Marshal(map[string]interface{}{
+		"worker_app":                   "synapse.app.media_repository",
+		"worker_name":                  mediaWorkerName,
+		"worker_replication_host":      utils.ComputeFQDN(s.Name, s.Namespace),
+		"worker_replication_http_port": synapseReplicationPort,
+		"worker_listeners": []map[string]interface{}{{
+			"type":           "http",
+			"port":           mediaWorkerPort,
+			"bind_addresses": []string{"0.0.0.0"},
+			"resources": []map[string]interface{}{{
+				"names": []string{"media"},
+			}},
+		}},
+	})
+	if err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: objectMeta,
+		Data:       map[string]string{"worker.yaml": string(worker)},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, cm, r.Scheme); err != nil {
+		return &corev1.ConfigMap{}, err
+	}
+
+	return cm, nil
+}
+
+// reconcileMediaWorkerDeployment is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the Deployment for the media repository worker to its
+// desired state.
+func (r *SynapseReconciler) reconcileMediaWorkerDeployment(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForMediaWorker := reconcile.SetObjectMeta(GetMediaWorkerResourceName(*s), s.Namespace, map[string]string{})
+	depl, err := r.deploymentForMediaWorker(s, objectMetaForMediaWorker)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		depl,
+		&appsv1.Deployment{},
+		reconcile.ReplaceDeploymentScheduling(depl),
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// deploymentForMediaWorker returns a Deployment object for the media
+// repository worker. The worker runs the Synapse image with the
+// configuration of the main process, and mounts the same data and media
+// store PVCs. As these PVCs may only be attached to a single node, the worker
+// Pod is scheduled on the node running Synapse.
+func (r *SynapseReconciler) deploymentForMediaWorker(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*appsv1.Deployment, error) {
+	ls := labelsForMediaWorker(s.Name)
+	replicas := int32(1)
+
+	dataSubPath := ""
+	if s.Spec.Storage != nil {
+		dataSubPath = s.Spec.Storage.SubPath
+	}
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
+					NodeSelector:     s.Spec.NodeSelector,
+					Tolerations:      s.Spec.Tolerations,
+					Affinity: &corev1.Affinity{
+						PodAffinity: &corev1.PodAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+								LabelSelector: &metav1.LabelSelector{
+									MatchLabels: labelsForSynapse(s.Name),
+								},
+								TopologyKey: "kubernetes.io/hostname",
+							}},
+						},
+					},
+					Containers: []corev1.Container{{
+						Image:           synapseImage(s),
+						ImagePullPolicy: s.Spec.ImagePullPolicy,
+						Name:            "synapse-media",
+						Args: []string{
+							"run",
+							"--config-path", "/data-homeserver/homeserver.yaml",
+							"--config-path", path.Join(homeserverSecretsMountPath, homeserverSecretsFilename),
+							"--config-path", path.Join(mediaWorkerConfigMountPath, "worker.yaml"),
+						},
+						Env: []corev1.EnvVar{{
+							Name:  "SYNAPSE_WORKER",
+							Value: "synapse.app.media_repository",
+						}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "homeserver",
+							MountPath: "/data-homeserver",
+						}, {
+							Name:      "homeserver-secrets",
+							MountPath: homeserverSecretsMountPath,
+							ReadOnly:  true,
+						}, {
+							Name:      "worker",
+							MountPath: mediaWorkerConfigMountPath,
+						}, {
+							Name:      "data-pv",
+							MountPath: "/data",
+							SubPath:   dataSubPath,
+						}, {
+							Name:      "media-store",
+							MountPath: synapseMediaStorePath,
+						}},
+						Ports: []corev1.ContainerPort{{
+							Name:          "media",
+							ContainerPort: mediaWorkerPort,
+						}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: "/health",
+									Port: intstr.FromInt(mediaWorkerPort),
+								},
+							},
+							InitialDelaySeconds: 10,
+						},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "homeserver",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: s.Name,
+								},
+							},
+						},
+					}, {
+						Name: "homeserver-secrets",
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{
+								SecretName: GetHomeserverSecretsResourceName(*s),
+							},
+						},
+					}, {
+						Name: "worker",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: GetMediaWorkerResourceName(*s),
+								},
+							},
+						},
+					}, {
+						Name: "data-pv",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: s.Name,
+							},
+						},
+					}, {
+						Name: "media-store",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: GetMediaStorePVCResourceName(*s),
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, dep, r.Scheme); err != nil {
+		return &appsv1.Deployment{}, err
+	}
+
+	return dep, nil
+}
+
+// reconcileMediaWorkerService is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the Service for the media repository worker to its desired
+// state.
+func (r *SynapseReconciler) reconcileMediaWorkerService(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForMediaWorker := reconcile.SetObjectMeta(GetMediaWorkerResourceName(*s), s.Namespace, map[string]string{})
+	desiredService, err := r.serviceForMediaWorker(s, objectMetaForMediaWorker)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredService,
+		&corev1.Service{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// serviceForMediaWorker returns a Service object for the media repository
+// worker. Requests to /_matrix/media must be routed to this Service.
+func (r *SynapseReconciler) serviceForMediaWorker(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: objectMeta,
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       "media",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       mediaWorkerPort,
+				TargetPort: intstr.FromInt(mediaWorkerPort),
+			}},
+			Selector: labelsForMediaWorker(s.Name),
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
+		return &corev1.Service{}, err
+	}
+
+	return service, nil
+}
+
+// updateSynapseConfigMapForMediaWorker is a function of type FnWithRequest,
+// to be called in the main reconciliation loop.
+//
+// It disables the media repository of the main process in homeserver.yaml,
+// and adds the replication listener used by the worker.
+func (r *SynapseReconciler) updateSynapseConfigMapForMediaWorker(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithMediaWorkerInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithMediaWorkerInfos hands the media repository over to
+// the media worker, and adds a replication listener to homeserver.yaml if
+// none exists yet
+func (r *SynapseReconciler) updateHomeserverWithMediaWorkerInfos(
+	_ client.Object,
+	homeserver map[string]interface{},
+) error {
+	homeserver["enable_media_repo"] = false
+	homeserver["media_instance_running_background_jobs"] = mediaWorkerName
+
+	listeners, _ := homeserver["listeners"].([]interface{})
+	for _, l := range listeners {
+		if listener, ok := l.(map[interface{}]interface{}); ok && listener["port"] == synapseReplicationPort {
+			return nil
+		}
+	}
+
+	homeserver["listeners"] = append(listeners, map[string]interface{}{
+		"port":           synapseReplicationPort,
+		"type":           "http",
+		"bind_addresses": []string{"0.0.0.0"},
+		"resources": []map[string]interface{}{{
+			"names": []string{"replication"},
+		}},
+	})
+	return nil
+}
+
+// cleanupMediaWorker is a function of type FnWithRequest, to be called in the
+// main reconciliation loop.
+//
+// When the media worker is disabled, it deletes the worker Deployment,
+// Service and ConfigMap left over from a previous configuration, if any.
+// The homeserver.yaml generated from the values is rendered again at each
+// reconciliation, so that the main process serves the media again.
+func (r *SynapseReconciler) cleanupMediaWorker(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForMediaWorker := types.NamespacedName{
+		Name:      GetMediaWorkerResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.ConfigMap{}} {
+		if err := r.Get(ctx, keyForMediaWorker, obj); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return subreconciler.RequeueWithError(err)
+		}
+
+		// Only delete resources managed by this Synapse instance
+		if !metav1.IsControlledBy(obj, s) {
+			continue
+		}
+
+		log.Info("Deleting the media worker resource", "Name", keyForMediaWorker.Name)
+		if err := r.Delete(ctx, obj); err != nil && !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	return subreconciler.ContinueReconciling()
+}
@@ -27,6 +27,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
 	pgov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -35,6 +37,95 @@ import (
 	"github.com/opdev/synapse-operator/helpers/reconcile"
 )
 
+// Finalizer set on Synapse objects whose PostgresCluster must survive their
+// deletion
+const retainDatabaseFinalizer = "synapse.opdev.io/retain-database"
+
+// processDatabaseRetention is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It adds the retain-database finalizer to the Synapse object if
+// Spec.Database.RetainOnDelete is set, and removes it otherwise. When a
+// Synapse object holding the finalizer is deleted, the owner reference is
+// removed from its PostgresCluster, so that the database is not garbage
+// collected, and the finalizer is released.
+func (r *SynapseReconciler) processDatabaseRetention(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if !s.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(s, retainDatabaseFinalizer) {
+			return subreconciler.DoNotRequeue()
+		}
+
+		if err := r.orphanPostgresCluster(ctx, s); err != nil {
+			log.Error(err, "Error retaining the PostgresCluster")
+			return subreconciler.RequeueWithError(err)
+		}
+
+		log.Info("Retaining PostgresCluster", "PostgresCluster.Name", GetPostgresClusterResourceName(*s))
+		controllerutil.RemoveFinalizer(s, retainDatabaseFinalizer)
+		if err := r.Update(ctx, s); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.DoNotRequeue()
+	}
+
+	retainDatabase := isDatabaseRetained(s)
+	if retainDatabase == controllerutil.ContainsFinalizer(s, retainDatabaseFinalizer) {
+		return subreconciler.ContinueReconciling()
+	}
+
+	if retainDatabase {
+		controllerutil.AddFinalizer(s, retainDatabaseFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(s, retainDatabaseFinalizer)
+	}
+	if err := r.Update(ctx, s); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.Requeue()
+}
+
+// isDatabaseRetained returns true if the PostgresCluster created for Synapse
+// must survive the deletion of the Synapse object
+func isDatabaseRetained(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.CreateNewPostgreSQL && s.Spec.Database != nil && s.Spec.Database.RetainOnDelete
+}
+
+// orphanPostgresCluster removes the owner reference to the given Synapse
+// instance from its PostgresCluster, if it exists.
+func (r *SynapseReconciler) orphanPostgresCluster(ctx context.Context, s *synapsev1alpha1.Synapse) error {
+	postgresCluster := &pgov1beta1.PostgresCluster{}
+	keyForPostgresCluster := types.NamespacedName{
+		Name:      GetPostgresClusterResourceName(*s),
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForPostgresCluster, postgresCluster); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(postgresCluster.DeepCopy())
+	removeOwnerReference(postgresCluster, s)
+	return r.Patch(ctx, postgresCluster, patch)
+}
+
+// removeOwnerReference removes any owner reference to owner from obj
+func removeOwnerReference(obj metav1.Object, owner metav1.Object) {
+	ownerReferences := []metav1.OwnerReference{}
+	for _, ownerReference := range obj.GetOwnerReferences() {
+		if ownerReference.UID != owner.GetUID() {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	obj.SetOwnerReferences(ownerReferences)
+}
+
 // reconcilePostgresClusterCR is a function of type FnWithRequest, to be
 // called in the main reconciliation loop.
 //
@@ -80,9 +171,8 @@ func (r *SynapseReconciler) reconcilePostgresClusterCR(ctx context.Context, req
 		return subreconciler.RequeueWithError(err)
 	}
 	if !r.isPostgresClusterReady(createdPostgresCluster) {
-		s.Status.DatabaseConnectionInfo.State = "NOT READY"
-		err, _ = r.updateSynapseStatus(ctx, s)
-		if err != nil {
+		reason := "waiting for the PostgresCluster instances to be ready"
+		if err := r.setDatabaseConnectionState(ctx, s, "NOT READY", reason); err != nil {
 			log.Error(err, "Error updating Synapse State")
 		}
 
@@ -112,22 +202,7 @@ func (r *SynapseReconciler) postgresClusterForSynapse(s *synapsev1alpha1.Synapse
 				},
 			}},
 			Backups: pgov1beta1.Backups{
-				PGBackRest: pgov1beta1.PGBackRestArchive{
-					Image: "registry.developers.crunchydata.com/crunchydata/crunchy-pgbackrest:ubi8-2.40-1",
-					Repos: []pgov1beta1.PGBackRestRepo{{
-						Name: "repo1",
-						Volume: &pgov1beta1.RepoPVC{
-							VolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
-								AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteOnce"},
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{
-										"storage": *resource.NewQuantity(1*1024*1024*1024, resource.BinarySI),
-									},
-								},
-							},
-						},
-					}},
-				},
+				PGBackRest: pgBackRestForSynapse(s),
 			},
 			Users: []pgov1beta1.PostgresUserSpec{{
 				Name:      "synapse",
@@ -234,3 +309,62 @@ func base64decode(to_decode []byte) string {
 	decoded_bytes, _ := b64.StdEncoding.DecodeString(string(to_decode))
 	return string(decoded_bytes)
 }
+
+// pgBackRestForSynapse returns the pgBackRest configuration of the
+// PostgresCluster. By default, backups are stored in a PersistentVolumeClaim
+// and no backup is scheduled. Both can be configured with
+// Spec.Database.PGBackRest.
+func pgBackRestForSynapse(s *synapsev1alpha1.Synapse) pgov1beta1.PGBackRestArchive {
+	repo := pgov1beta1.PGBackRestRepo{
+		Name: "repo1",
+		Volume: &pgov1beta1.RepoPVC{
+			VolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteOnce"},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						"storage": *resource.NewQuantity(1*1024*1024*1024, resource.BinarySI),
+					},
+				},
+			},
+		},
+	}
+
+	pgBackRest := pgov1beta1.PGBackRestArchive{
+		Image: "registry.developers.crunchydata.com/crunchydata/crunchy-pgbackrest:ubi8-2.40-1",
+	}
+
+	if s.Spec.Database != nil && s.Spec.Database.PGBackRest != nil {
+		config := s.Spec.Database.PGBackRest
+
+		if config.FullSchedule != "" || config.IncrementalSchedule != "" {
+			repo.BackupSchedules = &pgov1beta1.PGBackRestBackupSchedules{}
+			if config.FullSchedule != "" {
+				repo.BackupSchedules.Full = &config.FullSchedule
+			}
+			if config.IncrementalSchedule != "" {
+				repo.BackupSchedules.Incremental = &config.IncrementalSchedule
+			}
+		}
+
+		if config.RepoType == "s3" && config.S3 != nil {
+			repo.Volume = nil
+			repo.S3 = &pgov1beta1.RepoS3{
+				Bucket:   config.S3.Bucket,
+				Endpoint: config.S3.Endpoint,
+				Region:   config.S3.Region,
+			}
+			// The S3 credentials are passed to pgBackRest as an additional
+			// configuration file
+			pgBackRest.Configuration = []corev1.VolumeProjection{{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: config.S3.CredentialsSecret,
+					},
+				},
+			}}
+		}
+	}
+
+	pgBackRest.Repos = []pgov1beta1.PGBackRestRepo{repo}
+	return pgBackRest
+}
@@ -18,11 +18,17 @@ package synapse
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
 	subreconciler "github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
@@ -46,12 +52,7 @@ func (r *SynapseReconciler) reconcileSynapsePVC(ctx context.Context, req ctrl.Re
 		return subreconciler.RequeueWithError(err)
 	}
 
-	if err := reconcile.ReconcileResource(
-		ctx,
-		r.Client,
-		desiredPVC,
-		&corev1.PersistentVolumeClaim{},
-	); err != nil {
+	if err := reconcile.ReconcilePersistentVolumeClaim(ctx, r.Client, desiredPVC); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
 
@@ -72,6 +73,166 @@ func (r *SynapseReconciler) persistentVolumeClaimForSynapse(s *synapsev1alpha1.S
 					"storage": *resource.NewQuantity(5*1024*1024*1024, resource.BinarySI),
 				},
 			},
+			StorageClassName: storageClassNameForSynapse(s),
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, pvc, r.Scheme); err != nil {
+		return &corev1.PersistentVolumeClaim{}, err
+	}
+	return pvc, nil
+}
+
+// storageClassNameForSynapse returns the storage class requested for the
+// Synapse PVCs. nil is returned if none is requested, so that the cluster
+// default applies.
+func storageClassNameForSynapse(s *synapsev1alpha1.Synapse) *string {
+	if s.Spec.Storage == nil {
+		return nil
+	}
+	return s.Spec.Storage.StorageClassName
+}
+
+// waitForSynapsePVCBound is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It checks that the Synapse data PVC is Bound before the Synapse Deployment
+// is created. If the PVC uses a storage class with an Immediate volume
+// binding mode and is not Bound yet, the Synapse Status 'State' field is set
+// to 'StorageProvisioning' and the reconciliation is requeued. It is called
+// only if Spec.Storage.WaitForBound is set.
+func (r *SynapseReconciler) waitForSynapsePVCBound(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, types.NamespacedName{Name: s.Name, Namespace: s.Namespace}, pvc); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if pvc.Status.Phase == corev1.ClaimBound {
+		return subreconciler.ContinueReconciling()
+	}
+
+	storageClass, err := r.getStorageClassForPVC(ctx, pvc)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+	if !hasImmediateVolumeBinding(storageClass) {
+		return subreconciler.ContinueReconciling()
+	}
+
+	log.Info("Waiting for the Synapse PVC to be Bound", "PersistentVolumeClaim.Name", pvc.Name)
+
+	s.Status.State = "StorageProvisioning"
+	s.Status.Reason = "Waiting for PVC " + pvc.Name + " to be Bound"
+	if err, _ := r.updateSynapseStatus(ctx, s); err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.RequeueWithDelay(10 * time.Second)
+}
+
+// getStorageClassForPVC returns the StorageClass used by the given PVC. This
+// is either the StorageClass explicitly requested by the PVC, or the default
+// StorageClass of the cluster. nil is returned if no StorageClass applies.
+func (r *SynapseReconciler) getStorageClassForPVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*storagev1.StorageClass, error) {
+	if pvc.Spec.StorageClassName != nil {
+		if *pvc.Spec.StorageClassName == "" {
+			return nil, nil
+		}
+		storageClass := &storagev1.StorageClass{}
+		if err := r.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		return storageClass, nil
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.List(ctx, storageClasses); err != nil {
+		return nil, err
+	}
+	return getDefaultStorageClass(storageClasses.Items), nil
+}
+
+// getDefaultStorageClass returns the StorageClass annotated as the default
+// one, or nil if there is none.
+func getDefaultStorageClass(storageClasses []storagev1.StorageClass) *storagev1.StorageClass {
+	for i := range storageClasses {
+		if storageClasses[i].Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			return &storageClasses[i]
+		}
+	}
+	return nil
+}
+
+// hasImmediateVolumeBinding returns true if PVCs using the given
+// StorageClass are expected to be Bound before being consumed by a Pod.
+func hasImmediateVolumeBinding(storageClass *storagev1.StorageClass) bool {
+	if storageClass == nil {
+		return false
+	}
+	// The volume binding mode defaults to Immediate when not set
+	return storageClass.VolumeBindingMode == nil ||
+		*storageClass.VolumeBindingMode == storagev1.VolumeBindingImmediate
+}
+
+func GetMediaStorePVCResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "media"}, "-")
+}
+
+// reconcileSynapseMediaStorePVC is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It reconciles the PVC dedicated to the synapse media store to its desired
+// state. It is called only if Spec.Storage.MediaStore is set.
+func (r *SynapseReconciler) reconcileSynapseMediaStorePVC(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForMediaStore := reconcile.SetObjectMeta(GetMediaStorePVCResourceName(*s), s.Namespace, map[string]string{})
+
+	desiredPVC, err := r.persistentVolumeClaimForMediaStore(s, objectMetaForMediaStore)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcilePersistentVolumeClaim(ctx, r.Client, desiredPVC); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// persistentVolumeClaimForMediaStore returns a PVC object for the synapse
+// media store
+func (r *SynapseReconciler) persistentVolumeClaimForMediaStore(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.PersistentVolumeClaim, error) {
+	pvcmode := corev1.PersistentVolumeFilesystem
+
+	size, err := resource.ParseQuantity(s.Spec.Storage.MediaStore.Size)
+	if err != nil {
+		return &corev1.PersistentVolumeClaim{}, err
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: objectMeta,
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteOnce"},
+			VolumeMode:  &pvcmode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					"storage": size,
+				},
+			},
+			StorageClassName: storageClassNameForSynapse(s),
 		},
 	}
 
@@ -0,0 +1,306 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Port on which the managed Redis listens
+const redisPort = 6379
+
+func GetRedisResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "redis"}, "-")
+}
+
+// labelsForRedis returns the labels for selecting the Redis resources
+// belonging to the given synapse CR name.
+func labelsForRedis(name string) map[string]string {
+	return map[string]string{"app": "redis", "synapse_cr": name}
+}
+
+// isRedisEnabled returns true if a Redis instance is managed for Synapse
+func isRedisEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.Redis != nil && s.Spec.Redis.Enabled
+}
+
+// reconcileRedisDeployment is a function of type FnWithRequest, to be called
+// in the main reconciliation loop.
+//
+// It reconciles the Deployment for Redis to its desired state.
+func (r *SynapseReconciler) reconcileRedisDeployment(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForRedis := reconcile.SetObjectMeta(GetRedisResourceName(*s), s.Namespace, map[string]string{})
+	depl, err := r.deploymentForRedis(s, objectMetaForRedis)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		depl,
+		&appsv1.Deployment{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// deploymentForRedis returns a Redis Deployment object. Synapse only uses
+// Redis for pub/sub between its processes, no data needs to be persisted.
+func (r *SynapseReconciler) deploymentForRedis(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*appsv1.Deployment, error) {
+	ls := labelsForRedis(s.Name)
+	replicas := int32(1)
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: s.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Image: s.Spec.Redis.Image,
+						Name:  "redis",
+						Args:  []string{"--save", "", "--appendonly", "no"},
+						Ports: []corev1.ContainerPort{{
+							Name:          "redis",
+							ContainerPort: redisPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	utils.SetDefaultRequests(&dep.Spec.Template.Spec, r.defaultRequestsForSynapse(s))
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, dep, r.Scheme); err != nil {
+		return &appsv1.Deployment{}, err
+	}
+
+	return dep, nil
+}
+
+// reconcileRedisService is a function of type FnWithRequest, to be called in
+// the main reconciliation loop.
+//
+// It reconciles the Service for Redis to its desired state.
+func (r *SynapseReconciler) reconcileRedisService(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	objectMetaForRedis := reconcile.SetObjectMeta(GetRedisResourceName(*s), s.Namespace, map[string]string{})
+	desiredService, err := r.serviceForRedis(s, objectMetaForRedis)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if err := reconcile.ReconcileResource(
+		ctx,
+		r.Client,
+		desiredService,
+		&corev1.Service{},
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// serviceForRedis returns a Redis Service object
+func (r *SynapseReconciler) serviceForRedis(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: objectMeta,
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       "redis",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       redisPort,
+				TargetPort: intstr.FromInt(redisPort),
+			}},
+			Selector: labelsForRedis(s.Name),
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
+		return &corev1.Service{}, err
+	}
+
+	return service, nil
+}
+
+// updateSynapseConfigMapForRedis is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It configures the 'redis' section of homeserver.yaml to use the managed
+// Redis.
+func (r *SynapseReconciler) updateSynapseConfigMapForRedis(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithRedisInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithRedisInfos configures homeserver.yaml to use the
+// managed Redis
+func (r *SynapseReconciler) updateHomeserverWithRedisInfos(
+	obj client.Object,
+	homeserver map[string]interface{},
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+
+	homeserver["redis"] = map[string]interface{}{
+		"enabled": true,
+		"host":    utils.ComputeFQDN(GetRedisResourceName(*s), s.Namespace),
+		"port":    redisPort,
+	}
+	return nil
+}
+
+// cleanupRedis is a function of type FnWithRequest, to be called in the main
+// reconciliation loop.
+//
+// When the managed Redis is disabled, it deletes the Redis Deployment and
+// Service left over from a previous configuration, if any, and removes the
+// 'redis' section of homeserver.yaml if it points to the managed Redis.
+// Otherwise, the Redis resources would only be garbage collected with the
+// Synapse object.
+func (r *SynapseReconciler) cleanupRedis(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForRedis := types.NamespacedName{
+		Name:      GetRedisResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}} {
+		if err := r.Get(ctx, keyForRedis, obj); err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return subreconciler.RequeueWithError(err)
+		}
+
+		// Only delete resources managed by this Synapse instance
+		if !metav1.IsControlledBy(obj, s) {
+			continue
+		}
+
+		log.Info("Deleting the managed Redis resource", "Name", keyForRedis.Name)
+		if err := r.Delete(ctx, obj); err != nil && !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	keyForSynapse := types.NamespacedName{
+		Name:      s.Name,
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateConfigMap(
+		ctx,
+		r.Client,
+		keyForSynapse,
+		s,
+		r.updateHomeserverWithoutRedisInfos,
+		"homeserver.yaml",
+		utils.HomeserverSchema,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithoutRedisInfos removes the 'redis' section of
+// homeserver.yaml if it points to the managed Redis. A Redis configured by
+// the user is kept.
+func (r *SynapseReconciler) updateHomeserverWithoutRedisInfos(
+	obj client.Object,
+	homeserver map[string]interface{},
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+
+	redis, ok := homeserver["redis"].(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+
+	if redis["host"] == utils.ComputeFQDN(GetRedisResourceName(*s), s.Namespace) {
+		delete(homeserver, "redis")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+)
+
+// Key of the Secret holding the registration shared secret
+const registrationSharedSecretKey = "registration_shared_secret"
+
+func GetRegistrationSharedSecretResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "registration"}, "-")
+}
+
+// isSharedSecretRegistrationEnabled returns true unless the registration of
+// users with the registration_shared_secret is disabled
+func isSharedSecretRegistrationEnabled(s *synapsev1alpha1.Synapse) bool {
+	values := s.Spec.Homeserver.Values
+	return values == nil || values.SharedSecretRegistration == nil || *values.SharedSecretRegistration
+}
+
+// reconcileRegistrationSharedSecret is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It creates the Secret holding the registration_shared_secret, which allows
+// the registration of users (e.g. with the register_new_matrix_user script)
+// even if registration is otherwise disabled.
+func (r *SynapseReconciler) reconcileRegistrationSharedSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.reconcileGeneratedSecret(ctx, req, registrationSharedSecret)
+}
+
+// updateHomeserverSecretsForRegistrationSharedSecret is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It configures the 'registration_shared_secret' of the homeserver secrets
+// file with the generated secret, and references the Secret in the Synapse
+// Status.
+func (r *SynapseReconciler) updateHomeserverSecretsForRegistrationSharedSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if r, err := r.updateHomeserverSecretsForGeneratedSecret(ctx, req, registrationSharedSecret); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	s.Status.RegistrationSharedSecretRef = &synapsev1alpha1.SynapseStatusSecretKeyRef{
+		Name: GetRegistrationSharedSecretResourceName(*s),
+		Key:  registrationSharedSecretKey,
+	}
+
+	err, has_patched := r.updateSynapseStatus(ctx, s)
+	if err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+	if has_patched {
+		return subreconciler.Requeue()
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// removeRegistrationSharedSecret is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// When shared-secret registration is disabled, it removes the
+// 'registration_shared_secret' from the homeserver secrets file and its
+// reference from the Synapse Status, and deletes the generated Secret left
+// over from a previous configuration, if any.
+func (r *SynapseReconciler) removeRegistrationSharedSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if r, err := r.removeGeneratedSecret(ctx, req, registrationSharedSecret); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	s.Status.RegistrationSharedSecretRef = nil
+
+	err, has_patched := r.updateSynapseStatus(ctx, s)
+	if err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+	if has_patched {
+		return subreconciler.Requeue()
+	}
+
+	return subreconciler.ContinueReconciling()
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+)
+
+// Key of the Secret holding the worker replication secret
+const workerReplicationSecretKey = "worker_replication_secret"
+
+func GetWorkerReplicationSecretResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "replication"}, "-")
+}
+
+// isWorkerEnabled returns true if a Synapse worker, such as the media worker,
+// is deployed alongside the main process
+func isWorkerEnabled(s *synapsev1alpha1.Synapse) bool {
+	return isMediaWorkerEnabled(s)
+}
+
+// reconcileWorkerReplicationSecret is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It creates the Secret holding the worker_replication_secret, used by the
+// replication APIs to authenticate HTTP requests from workers.
+func (r *SynapseReconciler) reconcileWorkerReplicationSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.reconcileGeneratedSecret(ctx, req, workerReplicationSecret)
+}
+
+// updateHomeserverSecretsForWorkerReplicationSecret is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It configures the 'worker_replication_secret' of the homeserver secrets
+// file with the generated secret.
+func (r *SynapseReconciler) updateHomeserverSecretsForWorkerReplicationSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.updateHomeserverSecretsForGeneratedSecret(ctx, req, workerReplicationSecret)
+}
+
+// removeWorkerReplicationSecret is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// When no worker is enabled, it removes the 'worker_replication_secret' from
+// the homeserver secrets file, and deletes the generated Secret left over
+// from a previous configuration, if any.
+func (r *SynapseReconciler) removeWorkerReplicationSecret(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	return r.removeGeneratedSecret(ctx, req, workerReplicationSecret)
+}
@@ -0,0 +1,616 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/reconcile"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Key of the Secret holding the form secret
+const formSecretKey = "form_secret"
+
+// Name of the configuration file holding the secrets of Synapse, loaded in
+// addition to homeserver.yaml. It is kept in a Secret rather than in the
+// homeserver.yaml ConfigMap, as ConfigMaps are stored unencrypted.
+const homeserverSecretsFilename = "secrets.yaml"
+
+// Path where the homeserver secrets file is mounted in the Synapse container
+const homeserverSecretsMountPath = "/data-homeserver-secrets"
+
+func GetHomeserverSecretsResourceName(synapse synapsev1alpha1.Synapse) string {
+	return strings.Join([]string{synapse.Name, "homeserver", "secrets"}, "-")
+}
+
+// isExternalSecretsEnabled returns true if the secrets of Synapse are read
+// from an existing Secret, instead of being generated by the operator
+func isExternalSecretsEnabled(s *synapsev1alpha1.Synapse) bool {
+	return s.Spec.Secrets != nil && s.Spec.Secrets.SecretName != ""
+}
+
+// getRegistrationSharedSecretName returns the name of the Secret holding the
+// registration shared secret, either provided by the user or generated.
+func getRegistrationSharedSecretName(s *synapsev1alpha1.Synapse) string {
+	if isExternalSecretsEnabled(s) {
+		return s.Spec.Secrets.SecretName
+	}
+	return GetRegistrationSharedSecretResourceName(*s)
+}
+
+// reconcileHomeserverSecrets is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It creates the Secret holding the homeserver secrets file, in which the
+// registration_shared_secret, macaroon_secret_key, form_secret and
+// worker_replication_secret are then configured. The Secret is only created
+// if it doesn't exist yet, so that the generated form_secret isn't rotated
+// at each reconciliation.
+func (r *SynapseReconciler) reconcileHomeserverSecrets(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	keyForSecret := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := r.Get(ctx, keyForSecret, &corev1.Secret{}); err == nil {
+		return subreconciler.ContinueReconciling()
+	} else if !k8serrors.IsNotFound(err) {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	objectMeta := reconcile.SetObjectMeta(GetHomeserverSecretsResourceName(*s), s.Namespace, map[string]string{})
+	secret, err := r.secretForHomeserverSecrets(s, objectMeta)
+	if err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	log.Info("Creating homeserver secrets", "Secret.Name", secret.Name)
+	if err := r.Create(ctx, secret); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// secretForHomeserverSecrets returns a Secret object, holding the homeserver
+// secrets file with a newly generated form secret
+func (r *SynapseReconciler) secretForHomeserverSecrets(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Secret, error) {
+	formSecret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	content, err := yaml.Marshal(map[string]interface{}{formSecretKey: formSecret})
+	if err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: objectMeta,
+		StringData: map[string]string{homeserverSecretsFilename: string(content)},
+	}
+
+	// Set Synapse instance as the owner and controller
+	if err := ctrl.SetControllerReference(s, secret, r.Scheme); err != nil {
+		return &corev1.Secret{}, err
+	}
+
+	return secret, nil
+}
+
+// updateHomeserverSecretsForExternalSecrets is a function of type
+// FnWithRequest, to be called in the main reconciliation loop.
+//
+// It configures the 'registration_shared_secret', 'macaroon_secret_key' and
+// 'form_secret' of the homeserver secrets file with the values held by the
+// Secret given in Spec.Secrets.SecretName, and references the Secret in the
+// Synapse Status.
+func (r *SynapseReconciler) updateHomeserverSecretsForExternalSecrets(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var secret corev1.Secret
+	keyForSecret := types.NamespacedName{
+		Name:      s.Spec.Secrets.SecretName,
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForSecret, &secret); err != nil {
+		log.Error(err, "Error getting the Synapse secrets", "Secret.Name", keyForSecret.Name)
+		return subreconciler.RequeueWithError(err)
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithExternalSecrets(obj, homeserver, secret)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	s.Status.RegistrationSharedSecretRef = nil
+	if isSharedSecretRegistrationEnabled(s) {
+		s.Status.RegistrationSharedSecretRef = &synapsev1alpha1.SynapseStatusSecretKeyRef{
+			Name: secret.Name,
+			Key:  registrationSharedSecretKey,
+		}
+	}
+
+	err, has_patched := r.updateSynapseStatus(ctx, s)
+	if err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+	if has_patched {
+		return subreconciler.Requeue()
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithExternalSecrets sets the registration_shared_secret,
+// macaroon_secret_key and form_secret of the homeserver secrets file to the
+// values held by secret. All three keys must be present in the Secret,
+// except for the registration_shared_secret if shared-secret registration is
+// disabled, in which case it is removed from the homeserver secrets file.
+func (r *SynapseReconciler) updateHomeserverWithExternalSecrets(
+	obj client.Object,
+	homeserver map[string]interface{},
+	secret corev1.Secret,
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+
+	keys := []string{macaroonSecretKeyKey, formSecretKey}
+	if isSharedSecretRegistrationEnabled(s) {
+		keys = append([]string{registrationSharedSecretKey}, keys...)
+	} else {
+		delete(homeserver, registrationSharedSecretKey)
+	}
+
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok || len(value) == 0 {
+			return errors.New("missing " + key + " key in Secret " + secret.Name)
+		}
+		homeserver[key] = string(value)
+	}
+
+	return nil
+}
+
+// Keys of the Secret holding the reCAPTCHA keys, also used in the homeserver
+// secrets file
+const (
+	recaptchaPublicKeyKey  = "recaptcha_public_key"
+	recaptchaPrivateKeyKey = "recaptcha_private_key"
+)
+
+// isCaptchaEnabled returns true if a captcha is required on registration
+func isCaptchaEnabled(s *synapsev1alpha1.Synapse) bool {
+	values := s.Spec.Homeserver.Values
+	return values != nil && values.Captcha != nil && values.Captcha.Enabled
+}
+
+// updateHomeserverSecretsForCaptcha is a function of type FnWithRequest, to
+// be called in the main reconciliation loop.
+//
+// It configures the 'recaptcha_public_key' and 'recaptcha_private_key' of
+// the homeserver secrets file with the values held by the Secret given in
+// Spec.Homeserver.Values.Captcha.SecretName.
+func (r *SynapseReconciler) updateHomeserverSecretsForCaptcha(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var secret corev1.Secret
+	keyForSecret := types.NamespacedName{
+		Name:      s.Spec.Homeserver.Values.Captcha.SecretName,
+		Namespace: s.Namespace,
+	}
+	if err := r.Get(ctx, keyForSecret, &secret); err != nil {
+		log.Error(err, "Error getting the reCAPTCHA keys", "Secret.Name", keyForSecret.Name)
+		return subreconciler.RequeueWithError(err)
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithCaptchaKeys(obj, homeserver, secret)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithCaptchaKeys sets the recaptcha_public_key and
+// recaptcha_private_key of the homeserver secrets file to the values held by
+// secret. Both keys must be present in the Secret.
+func (r *SynapseReconciler) updateHomeserverWithCaptchaKeys(
+	_ client.Object,
+	homeserver map[string]interface{},
+	secret corev1.Secret,
+) error {
+	for _, key := range []string{recaptchaPublicKeyKey, recaptchaPrivateKeyKey} {
+		value, ok := secret.Data[key]
+		if !ok || len(value) == 0 {
+			return errors.New("missing " + key + " key in Secret " + secret.Name)
+		}
+		homeserver[key] = string(value)
+	}
+
+	return nil
+}
+
+// Keys of the Secret holding the SMTP credentials, also used in the email
+// section of the homeserver secrets file
+const (
+	smtpUserKey = "smtp_user"
+	smtpPassKey = "smtp_pass"
+)
+
+// isEmailEnabled returns true if Synapse sends emails
+func isEmailEnabled(s *synapsev1alpha1.Synapse) bool {
+	values := s.Spec.Homeserver.Values
+	return values != nil && values.Email != nil && values.Email.Enabled
+}
+
+// updateHomeserverSecretsForEmail is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// If email is enabled, it configures the 'email' section of the homeserver
+// secrets file with the SMTP credentials held by the Secret given in
+// Spec.Homeserver.Values.Email.SecretName, if any. Otherwise, it removes the
+// 'email' section configured while email was enabled, if any.
+func (r *SynapseReconciler) updateHomeserverSecretsForEmail(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var secret *corev1.Secret
+	if isEmailEnabled(s) && s.Spec.Homeserver.Values.Email.SecretName != "" {
+		secret = &corev1.Secret{}
+		keyForSecret := types.NamespacedName{
+			Name:      s.Spec.Homeserver.Values.Email.SecretName,
+			Namespace: s.Namespace,
+		}
+		if err := r.Get(ctx, keyForSecret, secret); err != nil {
+			log.Error(err, "Error getting the SMTP credentials", "Secret.Name", keyForSecret.Name)
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithEmailCredentials(obj, homeserver, secret)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithEmailCredentials sets the email section of the
+// homeserver secrets file. Synapse doesn't merge the sections of its
+// configuration files, so the whole section rendered in homeserver.yaml is
+// repeated here, with the smtp_user and smtp_pass held by secret, if not nil.
+// Both keys must then be present in the Secret. The section is removed if
+// email is disabled.
+func (r *SynapseReconciler) updateHomeserverWithEmailCredentials(
+	obj client.Object,
+	homeserver map[string]interface{},
+	secret *corev1.Secret,
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+
+	if !isEmailEnabled(s) {
+		delete(homeserver, "email")
+		return nil
+	}
+
+	emailConfig := emailToHomeserver(s.Spec.Homeserver.Values.Email)
+	if secret != nil {
+		for _, key := range []string{smtpUserKey, smtpPassKey} {
+			value, ok := secret.Data[key]
+			if !ok || len(value) == 0 {
+				return errors.New("missing " + key + " key in Secret " + secret.Name)
+			}
+			emailConfig[key] = string(value)
+		}
+	}
+	homeserver["email"] = emailConfig
+
+	return nil
+}
+
+// Key of the Secret holding the shared secret of an existing TURN server,
+// also used in the homeserver secrets file
+const turnSharedSecretKey = "turn_shared_secret"
+
+// updateHomeserverSecretsForTURN is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It sets the 'turn_shared_secret' of the homeserver secrets file to the
+// value held by the Secret given in Spec.Homeserver.Values.TURN.SecretName if
+// an existing TURN server is configured, or by the coturn Secret if coturn is
+// deployed. Otherwise, it removes the 'turn_shared_secret' configured
+// previously, if any.
+func (r *SynapseReconciler) updateHomeserverSecretsForTURN(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	var secret *corev1.Secret
+	var key string
+	keyForSecret := types.NamespacedName{Namespace: s.Namespace}
+	if s.Spec.Homeserver.Values.TURN != nil {
+		keyForSecret.Name = s.Spec.Homeserver.Values.TURN.SecretName
+		key = turnSharedSecretKey
+	} else if isCoturnEnabled(s) {
+		keyForSecret.Name = GetCoturnResourceName(*s)
+		key = coturnSharedSecretKey
+	}
+	if keyForSecret.Name != "" {
+		secret = &corev1.Secret{}
+		if err := r.Get(ctx, keyForSecret, secret); err != nil {
+			log.Error(err, "Error getting the TURN shared secret", "Secret.Name", keyForSecret.Name)
+			return subreconciler.RequeueWithError(err)
+		}
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithTURNSharedSecret(obj, homeserver, secret, key)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithTURNSharedSecret sets the turn_shared_secret of the
+// homeserver secrets file to the value held by the given key of secret, or
+// removes it if secret is nil. The key must be present in the Secret.
+func (r *SynapseReconciler) updateHomeserverWithTURNSharedSecret(
+	_ client.Object,
+	homeserver map[string]interface{},
+	secret *corev1.Secret,
+	key string,
+) error {
+	if secret == nil {
+		delete(homeserver, turnSharedSecretKey)
+		return nil
+	}
+
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return errors.New("missing " + key + " key in Secret " + secret.Name)
+	}
+	homeserver[turnSharedSecretKey] = string(value)
+
+	return nil
+}
+
+// Key of the Secrets holding the client secret of an OIDC identity provider
+const oidcClientSecretKey = "client_secret"
+
+// updateHomeserverSecretsForOIDC is a function of type FnWithRequest, to be
+// called in the main reconciliation loop.
+//
+// It configures the 'oidc_config' and 'oidc_providers' sections of the
+// homeserver secrets file with the client secrets held by the Secrets given
+// in the ClientSecretName of the OIDC identity providers, if any. Otherwise,
+// it removes the sections configured previously, if any.
+func (r *SynapseReconciler) updateHomeserverSecretsForOIDC(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	oidcs := []*synapsev1alpha1.SynapseHomeserverOIDC{}
+	if s.Spec.Homeserver.Values.OIDC != nil {
+		oidcs = append(oidcs, s.Spec.Homeserver.Values.OIDC)
+	}
+	for i := range s.Spec.Homeserver.Values.OIDCProviders {
+		oidcs = append(oidcs, &s.Spec.Homeserver.Values.OIDCProviders[i].SynapseHomeserverOIDC)
+	}
+
+	secrets := map[string]*corev1.Secret{}
+	for _, oidc := range oidcs {
+		if oidc.ClientSecretName == "" {
+			continue
+		}
+		if _, ok := secrets[oidc.ClientSecretName]; ok {
+			continue
+		}
+		secret := &corev1.Secret{}
+		keyForSecret := types.NamespacedName{
+			Name:      oidc.ClientSecretName,
+			Namespace: s.Namespace,
+		}
+		if err := r.Get(ctx, keyForSecret, secret); err != nil {
+			log.Error(err, "Error getting the OIDC client secret", "Secret.Name", keyForSecret.Name)
+			return subreconciler.RequeueWithError(err)
+		}
+		secrets[oidc.ClientSecretName] = secret
+	}
+
+	keyForHomeserverSecrets := types.NamespacedName{
+		Name:      GetHomeserverSecretsResourceName(*s),
+		Namespace: s.Namespace,
+	}
+
+	if err := utils.UpdateSecret(
+		ctx,
+		r.Client,
+		keyForHomeserverSecrets,
+		s,
+		func(obj client.Object, homeserver map[string]interface{}) error {
+			return r.updateHomeserverWithOIDCClientSecrets(obj, homeserver, secrets)
+		},
+		homeserverSecretsFilename,
+	); err != nil {
+		return subreconciler.RequeueWithError(err)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// updateHomeserverWithOIDCClientSecrets sets the oidc_config and
+// oidc_providers sections of the homeserver secrets file. As with the email
+// section, the whole section rendered in homeserver.yaml is repeated here,
+// with the client_secret held by the Secrets, indexed by name, for the
+// identity providers having a ClientSecretName. The key must then be present
+// in the Secrets. A section is removed if none of its identity providers has
+// a client secret.
+func (r *SynapseReconciler) updateHomeserverWithOIDCClientSecrets(
+	obj client.Object,
+	homeserver map[string]interface{},
+	secrets map[string]*corev1.Secret,
+) error {
+	s := obj.(*synapsev1alpha1.Synapse)
+	values := s.Spec.Homeserver.Values
+
+	delete(homeserver, "oidc_config")
+	if values.OIDC != nil && values.OIDC.ClientSecretName != "" {
+		oidcConfig := oidcToHomeserver(values.OIDC)
+		if err := setOIDCClientSecret(oidcConfig, values.OIDC, secrets); err != nil {
+			return err
+		}
+		homeserver["oidc_config"] = oidcConfig
+	}
+
+	delete(homeserver, "oidc_providers")
+	for _, provider := range values.OIDCProviders {
+		if provider.ClientSecretName == "" {
+			continue
+		}
+
+		oidcProviders := oidcProvidersToHomeserver(values.OIDCProviders)
+		for i := range values.OIDCProviders {
+			if err := setOIDCClientSecret(oidcProviders[i], &values.OIDCProviders[i].SynapseHomeserverOIDC, secrets); err != nil {
+				return err
+			}
+		}
+		homeserver["oidc_providers"] = oidcProviders
+		break
+	}
+
+	return nil
+}
+
+// setOIDCClientSecret sets the client_secret of the given rendered identity
+// provider to the value held by the Secret named by its ClientSecretName, if
+// any
+func setOIDCClientSecret(
+	oidcConfig map[string]interface{},
+	oidc *synapsev1alpha1.SynapseHomeserverOIDC,
+	secrets map[string]*corev1.Secret,
+) error {
+	if oidc.ClientSecretName == "" {
+		return nil
+	}
+
+	secret, ok := secrets[oidc.ClientSecretName]
+	if !ok {
+		return errors.New("missing Secret " + oidc.ClientSecretName)
+	}
+	value, ok := secret.Data[oidcClientSecretKey]
+	if !ok || len(value) == 0 {
+		return errors.New("missing " + oidcClientSecretKey + " key in Secret " + secret.Name)
+	}
+	oidcConfig[oidcClientSecretKey] = string(value)
+
+	return nil
+}
@@ -18,11 +18,13 @@ package synapse
 
 import (
 	"context"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	subreconciler "github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
@@ -51,12 +53,37 @@ func (r *SynapseReconciler) reconcileSynapseService(ctx context.Context, req ctr
 		r.Client,
 		desiredService,
 		&corev1.Service{},
+		func(current client.Object) {
+			// A sessionAffinityConfig left over from a previous ClientIP
+			// session affinity would be rejected with the None affinity
+			current.(*corev1.Service).Spec.SessionAffinityConfig = desiredService.Spec.SessionAffinityConfig
+
+			// The Prometheus annotations are removed once the metrics or
+			// the annotations are disabled
+			if _, ok := desiredService.Annotations["prometheus.io/scrape"]; !ok {
+				annotations := current.GetAnnotations()
+				for key := range prometheusAnnotations() {
+					delete(annotations, key)
+				}
+				current.SetAnnotations(annotations)
+			}
+		},
 	); err != nil {
 		return subreconciler.RequeueWithError(err)
 	}
 	return subreconciler.ContinueReconciling()
 }
 
+// prometheusAnnotations returns the annotations read by Prometheus instances
+// discovering their scrape targets through Service annotations
+func prometheusAnnotations() map[string]string {
+	return map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   strconv.Itoa(synapseMetricsPort),
+		"prometheus.io/path":   "/_synapse/metrics",
+	}
+}
+
 // serviceForSynapse returns a synapse Service object
 func (r *SynapseReconciler) serviceForSynapse(s *synapsev1alpha1.Synapse, objectMeta metav1.ObjectMeta) (*corev1.Service, error) {
 	service := &corev1.Service{
@@ -68,10 +95,55 @@ func (r *SynapseReconciler) serviceForSynapse(s *synapsev1alpha1.Synapse, object
 				Port:       8008,
 				TargetPort: intstr.FromInt(8008),
 			}},
-			Selector: labelsForSynapse(s.Name),
-			Type:     corev1.ServiceTypeClusterIP,
+			Selector:        labelsForSynapse(s.Name),
+			Type:            corev1.ServiceTypeClusterIP,
+			SessionAffinity: corev1.ServiceAffinityNone,
 		},
 	}
+
+	if s.Spec.Service != nil && s.Spec.Service.SessionAffinity == string(corev1.ServiceAffinityClientIP) {
+		service.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+		if s.Spec.Service.SessionAffinityTimeoutSeconds != 0 {
+			timeoutSeconds := s.Spec.Service.SessionAffinityTimeoutSeconds
+			service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+				ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeoutSeconds},
+			}
+		}
+	}
+
+	if isMetricsEnabled(s) {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       "metrics",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       synapseMetricsPort,
+			TargetPort: intstr.FromInt(synapseMetricsPort),
+		})
+		if s.Spec.Metrics.PrometheusAnnotations {
+			service.Annotations = prometheusAnnotations()
+		}
+	}
+
+	if isAdminListenerEnabled(s) {
+		// Only reachable in the cluster, as the Service is of type ClusterIP
+		port := int32(adminListenerPort(s.Spec.Homeserver.Values.AdminListener))
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       "admin",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+		})
+	}
+
+	if isMediaWorkerEnabled(s) {
+		// Workers reach the replication listener through the Synapse Service
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       "replication",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       synapseReplicationPort,
+			TargetPort: intstr.FromInt(synapseReplicationPort),
+		})
+	}
+
 	// Set Synapse instance as the owner and controller
 	if err := ctrl.SetControllerReference(s, service, r.Scheme); err != nil {
 		return &corev1.Service{}, err
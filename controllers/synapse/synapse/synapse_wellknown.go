@@ -0,0 +1,137 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package synapse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	subreconciler "github.com/opdev/subreconciler"
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	"github.com/opdev/synapse-operator/helpers/utils"
+)
+
+// Path of the well-known file used by remote servers for federation
+// discovery
+const serverWellKnownPath = "/.well-known/matrix/server"
+
+// HTTP client used to fetch the well-known file served by nginx
+var serverWellKnownClient = &http.Client{Timeout: 5 * time.Second}
+
+// isServerWellKnownChecked returns true if the last self-test succeeded for
+// the server currently advertised in the well-known server file
+func isServerWellKnownChecked(s *synapsev1alpha1.Synapse) bool {
+	return s.Status.ServerWellKnown != nil &&
+		s.Status.ServerWellKnown.State == "OK" &&
+		s.Status.ServerWellKnown.Server == wellKnownDelegatedServer(s)
+}
+
+// checkServerWellKnown is a function of type FnWithRequest, to be called in
+// the main reconciliation loop.
+//
+// It fetches /.well-known/matrix/server through the well-known Service, and
+// reports in the Synapse Status whether it is suitable for federation
+// discovery. The check only runs again when the advertised server changes. A
+// failed check doesn't stop the reconciliation, but is retried after a
+// minute, as nginx may still be starting.
+func (r *SynapseReconciler) checkServerWellKnown(ctx context.Context, req ctrl.Request) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	s := &synapsev1alpha1.Synapse{}
+	if r, err := r.getLatestSynapse(ctx, req, s); subreconciler.ShouldHaltOrRequeue(r, err) {
+		return r, err
+	}
+
+	if isServerWellKnownChecked(s) {
+		return subreconciler.ContinueReconciling()
+	}
+
+	url := "http://" + utils.ComputeFQDN(GetWellKnownResourceName(*s), s.Namespace) + ":" +
+		strconv.Itoa(wellKnownPort) + serverWellKnownPath
+	server, checkErr := fetchServerWellKnown(ctx, url)
+
+	s.Status.ServerWellKnown = &synapsev1alpha1.SynapseStatusServerWellKnown{State: "OK", Server: server}
+	if checkErr != nil {
+		s.Status.ServerWellKnown = &synapsev1alpha1.SynapseStatusServerWellKnown{
+			State:  "FAILED",
+			Reason: checkErr.Error(),
+		}
+	}
+
+	if err, _ := r.updateSynapseStatus(ctx, s); err != nil {
+		log.Error(err, "Error updating Synapse Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if checkErr != nil {
+		log.Info("The well-known server file is not suitable for federation discovery", "Reason", checkErr.Error())
+		return subreconciler.RequeueWithDelay(time.Minute)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// fetchServerWellKnown fetches the well-known server file at the given URL,
+// and returns the server advertised in its m.server field. An error is
+// returned if the file doesn't meet the expectations of federation testers:
+// a 200 response with an application/json Content-Type, a Cache-Control
+// header and a non-empty m.server field.
+func fetchServerWellKnown(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := serverWellKnownClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(serverWellKnownPath + " returned HTTP status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return "", errors.New(serverWellKnownPath + " must be served with the application/json Content-Type")
+	}
+
+	if resp.Header.Get("Cache-Control") == "" {
+		return "", errors.New(serverWellKnownPath + " must be served with a Cache-Control header")
+	}
+
+	wellKnown := struct {
+		Server string `json:"m.server"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&wellKnown); err != nil {
+		return "", errors.New(serverWellKnownPath + " is not valid JSON: " + err.Error())
+	}
+	if wellKnown.Server == "" {
+		return "", errors.New(serverWellKnownPath + " is missing the m.server field")
+	}
+
+	return wellKnown.Server, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/imdario/mergo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -21,15 +23,55 @@ func SetObjectMeta(name string, namespace string, labels map[string]string) meta
 	return objectMeta
 }
 
+// ReplaceFunc is called by ReconcileResource on the current resource, once
+// the desired resource is merged into it. As the merge ignores the empty
+// fields of the desired resource, a ReplaceFunc sets the fields which can be
+// cleared to their desired value.
+type ReplaceFunc func(current client.Object)
+
+// ReplaceDeploymentScheduling returns a ReplaceFunc setting the node
+// selector, tolerations and affinity of the current Deployment to those of
+// the desired one
+func ReplaceDeploymentScheduling(desired *appsv1.Deployment) ReplaceFunc {
+	return func(current client.Object) {
+		podSpec := &current.(*appsv1.Deployment).Spec.Template.Spec
+		podSpec.NodeSelector = desired.Spec.Template.Spec.NodeSelector
+		podSpec.Tolerations = desired.Spec.Template.Spec.Tolerations
+		podSpec.Affinity = desired.Spec.Template.Spec.Affinity
+	}
+}
+
+// ReplaceDeploymentStrategy returns a ReplaceFunc setting the strategy of the
+// current Deployment to the one of the desired Deployment, so that the
+// rolling update parameters are cleared when switching to Recreate
+func ReplaceDeploymentStrategy(desired *appsv1.Deployment) ReplaceFunc {
+	return func(current client.Object) {
+		current.(*appsv1.Deployment).Spec.Strategy = desired.Spec.Strategy
+	}
+}
+
+// ReplaceDeploymentDNS returns a ReplaceFunc setting the DNS policy and
+// config of the current Deployment to the ones of the desired Deployment, so
+// that they are reverted when removed from the Spec
+func ReplaceDeploymentDNS(desired *appsv1.Deployment) ReplaceFunc {
+	return func(current client.Object) {
+		podSpec := &current.(*appsv1.Deployment).Spec.Template.Spec
+		podSpec.DNSPolicy = desired.Spec.Template.Spec.DNSPolicy
+		podSpec.DNSConfig = desired.Spec.Template.Spec.DNSConfig
+	}
+}
+
 // Generic function to reconcile a Kubernetes resource
 // `current` should be an empty resource (e.g. &appsv1.Deployment{}). It is
 // populated by the actual current state of the resource in the initial GET
-// request.
+// request. The fields of the desired resource which can be cleared are set
+// on the existing resource with the given ReplaceFuncs.
 func ReconcileResource(
 	ctx context.Context,
 	rclient client.Client,
 	desired client.Object,
 	current client.Object,
+	replace ...ReplaceFunc,
 ) error {
 	log := ctrllog.FromContext(ctx)
 	log.Info(
@@ -85,6 +127,9 @@ func ReconcileResource(
 			log.Error(err, "Error in merge")
 			return err
 		}
+		for _, replaceFn := range replace {
+			replaceFn(current)
+		}
 
 		if err := rclient.Patch(ctx, current, patchDiff); err != nil {
 			log.Error(
@@ -106,3 +151,33 @@ func ReconcileResource(
 	)
 	return nil
 }
+
+// ReconcilePersistentVolumeClaim reconciles a PVC like ReconcileResource. As
+// the storage class of a PVC is immutable, the storage class of an existing
+// PVC is kept, even if the desired PVC requests a different one.
+func ReconcilePersistentVolumeClaim(
+	ctx context.Context,
+	rclient client.Client,
+	desired *corev1.PersistentVolumeClaim,
+) error {
+	log := ctrllog.FromContext(ctx)
+
+	existing := &corev1.PersistentVolumeClaim{}
+	key := types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}
+	if err := rclient.Get(ctx, key, existing); err == nil {
+		if desired.Spec.StorageClassName != nil && existing.Spec.StorageClassName != nil &&
+			*desired.Spec.StorageClassName != *existing.Spec.StorageClassName {
+			log.Info(
+				"Ignoring the storage class change of an existing PVC",
+				"Name", desired.Name,
+				"Namespace", desired.Namespace,
+				"StorageClassName", *existing.Spec.StorageClassName,
+			)
+		}
+		desired.Spec.StorageClassName = existing.Spec.StorageClassName
+	} else if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	return ReconcileResource(ctx, rclient, desired, &corev1.PersistentVolumeClaim{})
+}
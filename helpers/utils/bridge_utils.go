@@ -21,16 +21,189 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"time"
 
+	"github.com/opdev/subreconciler"
 	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// HeisenbridgeConfigDir is the directory in which the Heisenbridge ConfigMap
+// is mounted, in both the Heisenbridge and the Synapse containers
+const HeisenbridgeConfigDir = "/data-heisenbridge"
+
+// HeisenbridgeConfigPath is the path of the Heisenbridge registration file,
+// read by Heisenbridge and registered as an application service in Synapse
+const HeisenbridgeConfigPath = HeisenbridgeConfigDir + "/heisenbridge.yaml"
+
 func ComputeFQDN(name string, namespace string) string {
 	return strings.Join([]string{name, namespace, "svc", "cluster", "local"}, ".")
 }
 
+// SynapseClientVersionsURL returns the URL of the client versions endpoint of
+// the given Synapse instance, through the Synapse Service used by the bridges
+func SynapseClientVersionsURL(synapseName string, synapseNamespace string) string {
+	return "http://" + ComputeFQDN(synapseName, synapseNamespace) + ":8008/_matrix/client/versions"
+}
+
+// SynapseConnectivityInterval is the minimum interval between two self-tests
+// of the connection of a bridge to Synapse
+const SynapseConnectivityInterval = time.Minute
+
+// synapseConnectivityScript fetches the client versions endpoint at the URL
+// given as first argument, and fails unless Synapse answers with a 200
+// response listing at least one version
+const synapseConnectivityScript = `import json, sys, urllib.request
+versions = json.load(urllib.request.urlopen(sys.argv[1], timeout=5)).get("versions")
+if not versions:
+    sys.exit(sys.argv[1] + " doesn't list any version")
+`
+
+// SynapseConnectivityJob returns a Job checking that the client versions
+// endpoint of Synapse answers at the given URL. Its Pod has the given labels
+// of the bridge Pods, so that it goes through the same NetworkPolicies, and
+// runs the given bridge image, which ships Python. The Pod never becomes
+// ready, so that the bridge Service doesn't route traffic to it.
+func SynapseConnectivityJob(
+	objectMeta metav1.ObjectMeta,
+	labels map[string]string,
+	image string,
+	imagePullSecrets []corev1.LocalObjectReference,
+	url string,
+) *batchv1.Job {
+	backoffLimit := int32(0)
+
+	return &batchv1.Job{
+		ObjectMeta: objectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					ImagePullSecrets: imagePullSecrets,
+					Containers: []corev1.Container{{
+						Image:                    image,
+						Name:                     "selftest",
+						Command:                  []string{"python3", "-c", synapseConnectivityScript},
+						Args:                     []string{url},
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{Command: []string{"false"}},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// ReconcileSynapseConnectivity is shared by the checkSynapseConnectivity
+// subreconcilers of the bridge controllers. It runs the desired self-test
+// Job, built by SynapseConnectivityJob, and reports its result with
+// setStatus once it has finished. A finished Job is kept for
+// SynapseConnectivityInterval, so that its logs can be read, then deleted
+// so that the next reconciliation runs a new self-test. A failed self-test
+// doesn't stop the reconciliation, but is retried after
+// SynapseConnectivityInterval, as Synapse may still be starting.
+func ReconcileSynapseConnectivity(
+	ctx context.Context,
+	kubeClient client.Client,
+	desired *batchv1.Job,
+	setStatus func(status *synapsev1alpha1.BridgeStatusSynapseConnectivity) error,
+) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	current := &batchv1.Job{}
+	if err := kubeClient.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return subreconciler.RequeueWithError(err)
+		}
+
+		log.Info("Creating Synapse connectivity self-test Job", "Job.Name", desired.Name)
+		if err := kubeClient.Create(ctx, desired); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.RequeueWithDelay(5 * time.Second)
+	}
+
+	if current.DeletionTimestamp != nil {
+		return subreconciler.RequeueWithDelay(5 * time.Second)
+	}
+
+	finished := jobFinishedCondition(current)
+	if finished == nil {
+		return subreconciler.RequeueWithDelay(5 * time.Second)
+	}
+
+	elapsed := time.Since(finished.LastTransitionTime.Time)
+	if elapsed >= SynapseConnectivityInterval || !reflect.DeepEqual(
+		current.Spec.Template.Spec.Containers[0].Args,
+		desired.Spec.Template.Spec.Containers[0].Args,
+	) {
+		log.Info("Deleting the previous Synapse connectivity self-test Job", "Job.Name", current.Name)
+		if err := kubeClient.Delete(ctx, current, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return subreconciler.RequeueWithError(err)
+		}
+		return subreconciler.RequeueWithDelay(5 * time.Second)
+	}
+
+	status := synapseConnectivityStatus(current, finished)
+	if err := setStatus(status); err != nil {
+		log.Error(err, "Error updating Status")
+		return subreconciler.RequeueWithError(err)
+	}
+
+	if status.State != "OK" {
+		log.Info("Synapse can't be reached from the bridge", "Reason", status.Reason)
+		return subreconciler.RequeueWithDelay(SynapseConnectivityInterval - elapsed)
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// jobFinishedCondition returns the Complete or Failed condition of the given
+// Job, or nil if it is still running
+func jobFinishedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// synapseConnectivityStatus returns the result of the given finished
+// self-test Job
+func synapseConnectivityStatus(job *batchv1.Job, finished *batchv1.JobCondition) *synapsev1alpha1.BridgeStatusSynapseConnectivity {
+	status := &synapsev1alpha1.BridgeStatusSynapseConnectivity{
+		State: "OK",
+		URL:   job.Spec.Template.Spec.Containers[0].Args[0],
+	}
+
+	if finished.Type == batchv1.JobFailed {
+		status.State = "FAILED"
+		status.Reason = "the self-test Job " + job.Name + " failed, see its logs"
+		if finished.Message != "" {
+			status.Reason += ": " + finished.Message
+		}
+	}
+
+	return status
+}
+
 func GetSynapseServerName(s synapsev1alpha1.Synapse) (string, error) {
 	if s.Status.HomeserverConfiguration.ServerName != "" {
 		return s.Status.HomeserverConfiguration.ServerName, nil
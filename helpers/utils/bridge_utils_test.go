@@ -0,0 +1,95 @@
+//
+//This file contains unit tests for the bridge helpers of the utils package
+//
+
+package utils
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	synapsev1alpha1 "github.com/opdev/synapse-operator/apis/synapse/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Unit tests for the bridge helpers", Label("unit"), func() {
+	Context("When checking that a bridge can reach Synapse", func() {
+		var job *batchv1.Job
+
+		BeforeEach(func() {
+			job = SynapseConnectivityJob(
+				metav1.ObjectMeta{Name: "test-bridge-selftest", Namespace: "test-namespace"},
+				map[string]string{"app": "bridge", "bridge_cr": "test-bridge"},
+				"bridge:latest",
+				[]corev1.LocalObjectReference{{Name: "registry-credentials"}},
+				SynapseClientVersionsURL("test-synapse", "test-namespace"),
+			)
+		})
+
+		It("Should query the Synapse Service used by the bridges", func() {
+			Expect(SynapseClientVersionsURL("test-synapse", "test-namespace")).Should(
+				Equal("http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions"),
+			)
+		})
+
+		It("Should run a single attempt from a Pod with the labels of the bridge", func() {
+			Expect(*job.Spec.BackoffLimit).Should(BeZero())
+			Expect(job.Spec.Template.Labels).Should(Equal(map[string]string{"app": "bridge", "bridge_cr": "test-bridge"}))
+			Expect(job.Spec.Template.Spec.RestartPolicy).Should(Equal(corev1.RestartPolicyNever))
+			Expect(job.Spec.Template.Spec.ImagePullSecrets).Should(Equal([]corev1.LocalObjectReference{{Name: "registry-credentials"}}))
+
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).Should(Equal("bridge:latest"))
+			Expect(container.Command).Should(Equal([]string{"python3", "-c", synapseConnectivityScript}))
+			Expect(container.Args).Should(Equal([]string{
+				"http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions",
+			}))
+		})
+
+		It("Should keep the Pod out of the bridge Service", func() {
+			Expect(job.Spec.Template.Spec.Containers[0].ReadinessProbe.Exec.Command).Should(Equal([]string{"false"}))
+		})
+
+		It("Should wait for the Job to finish", func() {
+			Expect(jobFinishedCondition(job)).Should(BeNil())
+
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionFalse}}
+			Expect(jobFinishedCondition(job)).Should(BeNil())
+		})
+
+		It("Should report a completed Job as OK", func() {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:               batchv1.JobComplete,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now()),
+			}}
+
+			finished := jobFinishedCondition(job)
+			Expect(finished).ShouldNot(BeNil())
+			Expect(*synapseConnectivityStatus(job, finished)).Should(Equal(synapsev1alpha1.BridgeStatusSynapseConnectivity{
+				State: "OK",
+				URL:   "http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions",
+			}))
+		})
+
+		It("Should report a failed Job as FAILED", func() {
+			job.Status.Conditions = []batchv1.JobCondition{{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Message: "Job has reached the specified backoff limit",
+			}}
+
+			finished := jobFinishedCondition(job)
+			Expect(finished).ShouldNot(BeNil())
+			Expect(*synapseConnectivityStatus(job, finished)).Should(Equal(synapsev1alpha1.BridgeStatusSynapseConnectivity{
+				State:  "FAILED",
+				Reason: "the self-test Job test-bridge-selftest failed, see its logs: Job has reached the specified backoff limit",
+				URL:    "http://test-synapse.test-namespace.svc.cluster.local:8008/_matrix/client/versions",
+			}))
+		})
+	})
+})
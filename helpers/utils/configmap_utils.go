@@ -38,6 +38,7 @@ type updateDataFunc func(obj client.Object, data map[string]interface{}) error
 // * The Synapse object being reconciled
 // * The function to be called to actually update the ConfigMap's content
 // * The name of the file to update in the ConfigMap
+// * The schema the updated file is validated against, or nil
 func UpdateConfigMap(
 	ctx context.Context,
 	client client.Client,
@@ -45,6 +46,7 @@ func UpdateConfigMap(
 	obj client.Object,
 	updateData updateDataFunc,
 	filename string,
+	schema ConfigSchema,
 ) error {
 	cm := &corev1.ConfigMap{}
 
@@ -53,7 +55,7 @@ func UpdateConfigMap(
 		return err
 	}
 
-	if err := UpdateConfigMapData(cm, obj, updateData, filename); err != nil {
+	if err := UpdateConfigMapData(cm, obj, updateData, filename, schema); err != nil {
 		return err
 	}
 
@@ -70,6 +72,7 @@ func UpdateConfigMapData(
 	obj client.Object,
 	updateData updateDataFunc,
 	filename string,
+	schema ConfigSchema,
 ) error {
 	// Load file to update from ConfigMap
 	data, err := LoadYAMLFileFromConfigMapData(*cm, filename)
@@ -82,6 +85,14 @@ func UpdateConfigMapData(
 		return err
 	}
 
+	// Validate the updated content before writing it, to surface
+	// misconfigurations before they reach the workload
+	if schema != nil {
+		if err := schema.Validate(filename, data); err != nil {
+			return err
+		}
+	}
+
 	// Write new content into ConfigMap data
 	if err := writeYAMLFileToConfigMapData(cm, filename, data); err != nil {
 		return err
@@ -0,0 +1,70 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SetDefaultRequests sets the given resource requests on the containers and
+// init containers of the given Pod spec, for each resource which has neither
+// a request nor a limit. Limits are left alone, as Kubernetes defaults the
+// request of a resource to its limit. This lets the Pods pass the admission
+// of namespaces with a ResourceQuota on requests.
+func SetDefaultRequests(podSpec *corev1.PodSpec, defaults corev1.ResourceList) {
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			setDefaultRequests(&containers[i].Resources, defaults)
+		}
+	}
+}
+
+// MergeDefaultRequests returns the default resource requests of the operator,
+// overridden by the ones defined in the Spec of a custom resource
+func MergeDefaultRequests(operatorDefaults corev1.ResourceList, specDefaults corev1.ResourceList) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for name, quantity := range operatorDefaults {
+		requests[name] = quantity
+	}
+	for name, quantity := range specDefaults {
+		requests[name] = quantity
+	}
+	return requests
+}
+
+func setDefaultRequests(resources *corev1.ResourceRequirements, defaults corev1.ResourceList) {
+	// The requests may be shared with the custom resource the container is
+	// built from, so they are copied rather than modified in place
+	requests := corev1.ResourceList{}
+	for name, quantity := range resources.Requests {
+		requests[name] = quantity.DeepCopy()
+	}
+
+	for name, quantity := range defaults {
+		if _, ok := requests[name]; ok {
+			continue
+		}
+		if _, ok := resources.Limits[name]; ok {
+			continue
+		}
+		requests[name] = quantity.DeepCopy()
+	}
+
+	if len(requests) > 0 {
+		resources.Requests = requests
+	}
+}
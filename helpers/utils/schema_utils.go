@@ -0,0 +1,193 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* This file puts together the minimal schemas used to validate the
+configuration files generated by the operator */
+import (
+	"reflect"
+	"strings"
+)
+
+// Defines the kind of value expected for a configuration key
+type ConfigValueType string
+
+const (
+	ConfigString ConfigValueType = "string"
+	ConfigBool   ConfigValueType = "bool"
+	ConfigInt    ConfigValueType = "int"
+	ConfigMap    ConfigValueType = "map"
+	ConfigList   ConfigValueType = "list"
+)
+
+// ConfigSchemaField describes a configuration key, given by its dotted path
+// (e.g. 'database.args.port'), and the kind of value it holds. Optional keys
+// are only checked when present.
+type ConfigSchemaField struct {
+	Path     string
+	Type     ConfigValueType
+	Required bool
+}
+
+type ConfigSchema []ConfigSchemaField
+
+// HomeserverSchema is a minimal schema of the Synapse homeserver.yaml,
+// covering the keys managed by the operator.
+var HomeserverSchema = ConfigSchema{
+	{Path: "server_name", Type: ConfigString, Required: true},
+	{Path: "report_stats", Type: ConfigBool, Required: true},
+	{Path: "listeners", Type: ConfigList},
+	{Path: "database", Type: ConfigMap},
+	{Path: "database.name", Type: ConfigString},
+	{Path: "database.args", Type: ConfigMap},
+	{Path: "database.args.host", Type: ConfigString},
+	{Path: "database.args.port", Type: ConfigInt},
+	{Path: "media_store_path", Type: ConfigString},
+	{Path: "log_config", Type: ConfigString},
+	{Path: "signing_key_path", Type: ConfigString},
+	{Path: "trusted_key_servers", Type: ConfigList},
+	{Path: "suppress_key_server_warning", Type: ConfigBool},
+	{Path: "enable_registration", Type: ConfigBool},
+	{Path: "enable_registration_captcha", Type: ConfigBool},
+	{Path: "registration_shared_secret", Type: ConfigString},
+	{Path: "macaroon_secret_key", Type: ConfigString},
+	{Path: "worker_replication_secret", Type: ConfigString},
+	{Path: "password_config", Type: ConfigMap},
+	{Path: "password_config.enabled", Type: ConfigBool},
+	{Path: "oidc_providers", Type: ConfigList},
+	{Path: "app_service_config_files", Type: ConfigList},
+	{Path: "turn_uris", Type: ConfigList},
+	{Path: "turn_shared_secret", Type: ConfigString},
+	{Path: "enable_metrics", Type: ConfigBool},
+	{Path: "gc_thresholds", Type: ConfigList},
+	{Path: "allow_public_rooms_over_federation", Type: ConfigBool},
+	{Path: "allow_public_rooms_without_auth", Type: ConfigBool},
+	{Path: "serve_server_wellknown", Type: ConfigBool},
+	{Path: "url_preview_enabled", Type: ConfigBool},
+	{Path: "url_preview_ip_range_blacklist", Type: ConfigList},
+	{Path: "experimental_features", Type: ConfigMap},
+	{Path: "redis", Type: ConfigMap},
+	{Path: "redis.enabled", Type: ConfigBool},
+	{Path: "redis.host", Type: ConfigString},
+	{Path: "redis.port", Type: ConfigInt},
+}
+
+// MautrixSignalSchema is a minimal schema of the mautrix-signal config.yaml,
+// covering the keys managed by the operator.
+var MautrixSignalSchema = ConfigSchema{
+	{Path: "homeserver", Type: ConfigMap, Required: true},
+	{Path: "homeserver.address", Type: ConfigString, Required: true},
+	{Path: "homeserver.domain", Type: ConfigString, Required: true},
+	{Path: "homeserver.connection_limit", Type: ConfigInt},
+	{Path: "homeserver.http_retry_count", Type: ConfigInt},
+	{Path: "homeserver.async_media", Type: ConfigBool},
+	{Path: "homeserver.software", Type: ConfigString},
+	{Path: "homeserver.asmux", Type: ConfigBool},
+	{Path: "appservice", Type: ConfigMap, Required: true},
+	{Path: "appservice.address", Type: ConfigString, Required: true},
+	{Path: "appservice.port", Type: ConfigInt},
+	{Path: "appservice.ephemeral_events", Type: ConfigBool},
+	{Path: "signal", Type: ConfigMap, Required: true},
+	{Path: "signal.socket_path", Type: ConfigString, Required: true},
+	{Path: "bridge", Type: ConfigMap, Required: true},
+	{Path: "bridge.permissions", Type: ConfigMap},
+	{Path: "bridge.command_prefix", Type: ConfigString},
+	{Path: "bridge.encryption", Type: ConfigMap},
+	{Path: "bridge.relay", Type: ConfigMap},
+	{Path: "logging", Type: ConfigMap, Required: true},
+}
+
+// ConfigSchemaError is returned when a configuration file doesn't match its
+// schema. Retrying won't help until the configuration is modified.
+type ConfigSchemaError struct {
+	Filename string
+	Reason   string
+}
+
+func (e *ConfigSchemaError) Error() string {
+	return "invalid " + e.Filename + ": " + e.Reason
+}
+
+// Validate returns a ConfigSchemaError describing the first key of the given
+// configuration file which doesn't match the schema, if any.
+func (schema ConfigSchema) Validate(filename string, config map[string]interface{}) error {
+	for _, field := range schema {
+		value, found := lookupConfigValue(config, field.Path)
+		if !found {
+			if field.Required {
+				return &ConfigSchemaError{Filename: filename, Reason: "missing required key '" + field.Path + "'"}
+			}
+			continue
+		}
+
+		if !hasConfigValueType(value, field.Type) {
+			return &ConfigSchemaError{
+				Filename: filename,
+				Reason:   "key '" + field.Path + "' must be of type " + string(field.Type),
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupConfigValue returns the value found at the given dotted path of the
+// configuration, and whether it was found. Nested sections can be of any map
+// type, as returned by the YAML parser or set by the operator.
+func lookupConfigValue(config map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = config
+
+	for _, key := range strings.Split(path, ".") {
+		section := reflect.ValueOf(current)
+		if section.Kind() != reflect.Map || section.Type().Key().Kind() != reflect.String &&
+			section.Type().Key().Kind() != reflect.Interface {
+			return nil, false
+		}
+
+		value := section.MapIndex(reflect.ValueOf(key).Convert(section.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		current = value.Interface()
+	}
+
+	return current, true
+}
+
+// hasConfigValueType returns true if the value is of the given kind. A null
+// value is accepted for any kind, Synapse falling back to its default.
+func hasConfigValueType(value interface{}, valueType ConfigValueType) bool {
+	if value == nil {
+		return true
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return valueType == ConfigString
+	case reflect.Bool:
+		return valueType == ConfigBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return valueType == ConfigInt
+	case reflect.Map:
+		return valueType == ConfigMap
+	case reflect.Slice, reflect.Array:
+		return valueType == ConfigList
+	default:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+/* This file puts together generic functions for Secret manipulation */
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/opdev/subreconciler"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// CheckImagePullSecrets is shared by the checkImagePullSecrets
+// subreconcilers of the controllers. It verifies that the given image pull
+// Secrets exist in the given namespace. If one is missing, setFailedState is
+// called with the reason and the reconciliation is requeued, waiting for the
+// Secret to be created.
+func CheckImagePullSecrets(
+	ctx context.Context,
+	kubeClient client.Client,
+	namespace string,
+	imagePullSecrets []corev1.LocalObjectReference,
+	setFailedState func(reason string) error,
+) (*ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	for _, imagePullSecret := range imagePullSecrets {
+		if err := kubeClient.Get(
+			ctx,
+			types.NamespacedName{Name: imagePullSecret.Name, Namespace: namespace},
+			&corev1.Secret{},
+		); err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return subreconciler.RequeueWithError(err)
+			}
+
+			reason := "image pull Secret " + imagePullSecret.Name + " not found in namespace " + namespace
+			if err := setFailedState(reason); err != nil {
+				log.Error(err, "Error updating State")
+			}
+
+			log.Info(reason)
+			return subreconciler.RequeueWithDelay(10 * time.Second)
+		}
+	}
+
+	return subreconciler.ContinueReconciling()
+}
+
+// A generic function to update an existing Secret holding a YAML file. It
+// takes as arguments:
+// * The context
+// * The key (name and namespace) of the Secret to update
+// * The Synapse object being reconciled
+// * The function to be called to actually update the Secret's content
+// * The name of the file to update in the Secret
+func UpdateSecret(
+	ctx context.Context,
+	client client.Client,
+	key types.NamespacedName,
+	obj client.Object,
+	updateData updateDataFunc,
+	filename string,
+) error {
+	secret := &corev1.Secret{}
+
+	// Get latest Secret version
+	if err := client.Get(ctx, key, secret); err != nil {
+		return err
+	}
+
+	// Load file to update from Secret
+	data, err := LoadYAMLFileFromSecretData(*secret, filename)
+	if err != nil {
+		return err
+	}
+
+	// Update the content of the file
+	if err := updateData(obj, data); err != nil {
+		return err
+	}
+
+	// Write new content into Secret data
+	bytesContent, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	secret.Data[filename] = bytesContent
+
+	// Update Secret
+	if err := client.Update(ctx, secret); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func LoadYAMLFileFromSecretData(
+	secret corev1.Secret,
+	filename string,
+) (map[string]interface{}, error) {
+	yamlContent := map[string]interface{}{}
+
+	content, ok := secret.Data[filename]
+	if !ok {
+		err := errors.New("missing " + filename + " in Secret " + secret.Name)
+		return yamlContent, err
+	}
+	if err := yaml.Unmarshal(content, yamlContent); err != nil {
+		return yamlContent, err
+	}
+
+	return yamlContent, nil
+}
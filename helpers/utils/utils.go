@@ -16,7 +16,12 @@ limitations under the License.
 
 package utils
 
-import "gopkg.in/yaml.v2"
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"gopkg.in/yaml.v2"
+)
 
 func ConvertStructToMap(in interface{}) (map[string]interface{}, error) {
 	var intermediate []byte
@@ -45,3 +50,13 @@ func BoolToString(b bool) string {
 	}
 	return "false"
 }
+
+// GenerateRandomString returns a random, URL-safe, base64 encoded string built
+// from n random bytes. It is intended to be used to generate secrets.
+func GenerateRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
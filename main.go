@@ -24,6 +24,8 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -57,11 +59,19 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var defaultCPURequest string
+	var defaultMemoryRequest string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultCPURequest, "default-cpu-request", "",
+		"CPU request set on the managed containers without CPU request nor limit, e.g. 100m. "+
+			"Useful in namespaces with a ResourceQuota.")
+	flag.StringVar(&defaultMemoryRequest, "default-memory-request", "",
+		"Memory request set on the managed containers without memory request nor limit, e.g. 128Mi. "+
+			"Useful in namespaces with a ResourceQuota.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -70,6 +80,22 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	defaultRequests := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    defaultCPURequest,
+		corev1.ResourceMemory: defaultMemoryRequest,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			setupLog.Error(err, "invalid default request", "resource", name)
+			os.Exit(1)
+		}
+		defaultRequests[name] = quantity
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -84,26 +110,39 @@ func main() {
 	}
 
 	if err = (&synapsecontroller.SynapseReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		DefaultRequests: defaultRequests,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Synapse")
 		os.Exit(1)
 	}
 	if err = (&mautrixsignalcontroller.MautrixSignalReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		DefaultRequests: defaultRequests,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MautrixSignal")
 		os.Exit(1)
 	}
 	if err = (&heisenbridgecontroller.HeisenbridgeReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		DefaultRequests: defaultRequests,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Heisenbridge")
 		os.Exit(1)
 	}
+	// The validating webhook requires a serving certificate, provisioned by
+	// cert-manager once the [WEBHOOK] and [CERTMANAGER] sections of
+	// config/default are uncommented. It is opt-in, so that the operator
+	// keeps running in environments without webhook support.
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&synapsev1alpha1.Synapse{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Synapse")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {